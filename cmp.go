@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+)
+
+// RunCompareMode implements the "cmp" subcommand: jtl-stats-processor cmp baseline.jtl candidate.jtl [-o out.csv] [-threshold pct]
+func RunCompareMode(args []string) {
+	cliArgs, err := ProcessCompareCommandLineOptions(args)
+	DieIfError(err)
+
+	baselineSummarizer, baselinePercentiles, err := SummarizerForJtlFileAtPath(cliArgs.PathToBaselineJtlFile)
+	DieIfError(err)
+
+	candidateSummarizer, candidatePercentiles, err := SummarizerForJtlFileAtPath(cliArgs.PathToCandidateJtlFile)
+	DieIfError(err)
+
+	report := CompareSummarizers(baselineSummarizer, baselinePercentiles, candidateSummarizer, candidatePercentiles)
+
+	var reportText string
+	if cliArgs.PathToCsvOutputFile != "" {
+		reportText = report.AsCsv()
+
+		err := WriteSummaryToFile(cliArgs.PathToCsvOutputFile, reportText)
+		DieIfError(err)
+	} else {
+		reportText = report.AsTable()
+		fmt.Print(reportText)
+	}
+
+	if cliArgs.RegressionThresholdPercent > 0 && report.HasALatencyRegressionExceeding(cliArgs.RegressionThresholdPercent) {
+		os.Exit(2)
+	}
+}
+
+type CompareCommandLineArguments struct {
+	PathToBaselineJtlFile      string
+	PathToCandidateJtlFile     string
+	PathToCsvOutputFile        string
+	RegressionThresholdPercent float64
+}
+
+func ProcessCompareCommandLineOptions(args []string) (*CompareCommandLineArguments, error) {
+	cliArgs := &CompareCommandLineArguments{}
+
+	flagSet := flag.NewFlagSet("cmp", flag.ExitOnError)
+	flagSet.StringVar(&cliArgs.PathToCsvOutputFile, "o", "", "Path to CSV file for the delta report (default: human-readable table to stdout)")
+	flagSet.Float64Var(&cliArgs.RegressionThresholdPercent, "threshold", 0, "Exit code 2 if any latency percentile regresses by more than this percent")
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flagSet.NArg() != 2 {
+		return nil, fmt.Errorf("usage: jtl-stats-processor cmp baseline.jtl candidate.jtl [-o out.csv] [-threshold pct]")
+	}
+
+	cliArgs.PathToBaselineJtlFile = flagSet.Arg(0)
+	cliArgs.PathToCandidateJtlFile = flagSet.Arg(1)
+
+	return cliArgs, nil
+}
+
+func SummarizerForJtlFileAtPath(path string) (*Summarizer, *PercentileIndex, error) {
+	summarizer, rowsThatCannotBeProcessed, err := NewSummarizerFromCsv(path, false, 0, DefaultHistogramSignificantFigures)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	LogAnyRowsThatCannotBeProcessed(rowsThatCannotBeProcessed)
+
+	return summarizer, summarizer.PercentileIndex(), nil
+}
+
+// CompareMetricRow is one line of the delta report: a single metric for a single category/key,
+// present in at least one of the baseline or candidate runs.
+type CompareMetricRow struct {
+	Category        string
+	Key             string
+	Metric          string
+	Baseline        float64
+	Candidate       float64
+	OnlyInBaseline  bool
+	OnlyInCandidate bool
+}
+
+func (r *CompareMetricRow) AbsoluteDelta() float64 {
+	return r.Candidate - r.Baseline
+}
+
+func (r *CompareMetricRow) PercentDelta() float64 {
+	if r.Baseline == 0 {
+		if r.Candidate == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+
+	return r.AbsoluteDelta() / r.Baseline * 100
+}
+
+// IsALatencyRegression reports whether this row is a TTFB/TTLB percentile metric whose
+// candidate value is worse (higher) than the baseline by more than thresholdPercent.
+func (r *CompareMetricRow) IsALatencyRegression(thresholdPercent float64) bool {
+	if r.OnlyInBaseline || r.OnlyInCandidate {
+		return false
+	}
+
+	isLatencyMetric := false
+	for _, prefix := range []string{"ttfb_", "ttlb_"} {
+		if len(r.Metric) >= len(prefix) && r.Metric[:len(prefix)] == prefix {
+			isLatencyMetric = true
+		}
+	}
+
+	return isLatencyMetric && r.PercentDelta() > thresholdPercent
+}
+
+type CompareReport struct {
+	Rows []*CompareMetricRow
+}
+
+func (report *CompareReport) HasALatencyRegressionExceeding(thresholdPercent float64) bool {
+	for _, row := range report.Rows {
+		if row.IsALatencyRegression(thresholdPercent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (report *CompareReport) AsCsv() string {
+	textBuffer := &bytes.Buffer{}
+
+	textBuffer.WriteString("category,key,metric,baseline,candidate,abs_delta,pct_delta\n")
+
+	for _, row := range report.Rows {
+		textBuffer.WriteString(fmt.Sprintf("%s,%s,%s,%0.4f,%0.4f,%0.4f,%0.2f\n",
+			row.Category, row.Key, row.Metric, row.Baseline, row.Candidate, row.AbsoluteDelta(), row.PercentDelta()))
+	}
+
+	return textBuffer.String()
+}
+
+func (report *CompareReport) AsTable() string {
+	textBuffer := &bytes.Buffer{}
+
+	textBuffer.WriteString(fmt.Sprintf("%-20s %-30s %-14s %12s %12s %12s %10s\n",
+		"CATEGORY", "KEY", "METRIC", "BASELINE", "CANDIDATE", "ABS DELTA", "PCT DELTA"))
+
+	for _, row := range report.Rows {
+		if row.OnlyInBaseline {
+			textBuffer.WriteString(fmt.Sprintf("%-20s %-30s %-14s %12s\n", row.Category, row.Key, row.Metric, "only in baseline"))
+			continue
+		}
+
+		if row.OnlyInCandidate {
+			textBuffer.WriteString(fmt.Sprintf("%-20s %-30s %-14s %12s\n", row.Category, row.Key, row.Metric, "only in candidate"))
+			continue
+		}
+
+		textBuffer.WriteString(fmt.Sprintf("%-20s %-30s %-14s %12.4f %12.4f %12.4f %9.2f%%\n",
+			row.Category, row.Key, row.Metric, row.Baseline, row.Candidate, row.AbsoluteDelta(), row.PercentDelta()))
+	}
+
+	return textBuffer.String()
+}
+
+// CompareSummarizers builds the delta report for the aggregate and for each method+uripath and
+// responseCode key present in either run.
+func CompareSummarizers(baseline *Summarizer, baselinePercentiles *PercentileIndex, candidate *Summarizer, candidatePercentiles *PercentileIndex) *CompareReport {
+	report := &CompareReport{}
+
+	baselineAggregate, _ := baseline.AggregateSummary()
+	candidateAggregate, _ := candidate.AggregateSummary()
+	report.Rows = append(report.Rows, CompareMetricRowsForAggregates("aggregate", "", baselineAggregate, baselinePercentiles, candidateAggregate, candidatePercentiles)...)
+
+	report.Rows = append(report.Rows, CompareMetricRowsForColumn(baseline, baselinePercentiles, candidate, candidatePercentiles, ColumnMethodAndURIPath, "method+uripath")...)
+	report.Rows = append(report.Rows, CompareMetricRowsForColumn(baseline, baselinePercentiles, candidate, candidatePercentiles, ColumnResponseCode, "responseCode")...)
+
+	return report
+}
+
+func CompareMetricRowsForColumn(baseline *Summarizer, baselinePercentiles *PercentileIndex, candidate *Summarizer, candidatePercentiles *PercentileIndex, column DataColumn, categoryLabel string) []*CompareMetricRow {
+	baselineByKey := IndexColumnSummariesByKey(baseline, column)
+	candidateByKey := IndexColumnSummariesByKey(candidate, column)
+
+	rows := []*CompareMetricRow{}
+
+	for key, baselineSummary := range baselineByKey {
+		candidateSummary, presentInCandidate := candidateByKey[key]
+		if !presentInCandidate {
+			rows = append(rows, &CompareMetricRow{Category: categoryLabel, Key: key, Metric: "count", OnlyInBaseline: true})
+			continue
+		}
+
+		rows = append(rows, CompareMetricRowsForKeys(categoryLabel, key, baselineSummary, baselinePercentiles, candidateSummary, candidatePercentiles)...)
+	}
+
+	for key := range candidateByKey {
+		if _, presentInBaseline := baselineByKey[key]; !presentInBaseline {
+			rows = append(rows, &CompareMetricRow{Category: categoryLabel, Key: key, Metric: "count", OnlyInCandidate: true})
+		}
+	}
+
+	return rows
+}
+
+func IndexColumnSummariesByKey(summarizer *Summarizer, column DataColumn) map[string]*ColumnSummary {
+	summaries, _ := summarizer.SummariesForTheColumn(column)
+
+	byKey := make(map[string]*ColumnSummary, len(summaries))
+	for _, s := range summaries {
+		byKey[s.KeyAsAString()] = s
+	}
+
+	return byKey
+}
+
+func CompareMetricRowsForKeys(category string, key string, baseline *ColumnSummary, baselinePercentiles *PercentileIndex, candidate *ColumnSummary, candidatePercentiles *PercentileIndex) []*CompareMetricRow {
+	baselineFailureRate := FailureRatePercent(baseline.NumberOfMatchingRequests, uint(baseline.NumberOfSuccessfulRequests))
+	candidateFailureRate := FailureRatePercent(candidate.NumberOfMatchingRequests, uint(candidate.NumberOfSuccessfulRequests))
+
+	return []*CompareMetricRow{
+		{Category: category, Key: key, Metric: "count", Baseline: float64(baseline.NumberOfMatchingRequests), Candidate: float64(candidate.NumberOfMatchingRequests)},
+		{Category: category, Key: key, Metric: "failure_rate_pct", Baseline: baselineFailureRate, Candidate: candidateFailureRate},
+		{Category: category, Key: key, Metric: "ttfb_mean", Baseline: baseline.TimeToFirstByteStatistics.Mean, Candidate: candidate.TimeToFirstByteStatistics.Mean},
+		{Category: category, Key: key, Metric: "ttfb_p50", Baseline: baselinePercentiles.TTFBPercentile(category, key, 50), Candidate: candidatePercentiles.TTFBPercentile(category, key, 50)},
+		{Category: category, Key: key, Metric: "ttfb_p95", Baseline: baselinePercentiles.TTFBPercentile(category, key, 95), Candidate: candidatePercentiles.TTFBPercentile(category, key, 95)},
+		{Category: category, Key: key, Metric: "ttfb_p99", Baseline: baselinePercentiles.TTFBPercentile(category, key, 99), Candidate: candidatePercentiles.TTFBPercentile(category, key, 99)},
+		{Category: category, Key: key, Metric: "ttlb_mean", Baseline: baseline.TimeToLastByteStatistics.Mean, Candidate: candidate.TimeToLastByteStatistics.Mean},
+		{Category: category, Key: key, Metric: "ttlb_p50", Baseline: baselinePercentiles.TTLBPercentile(category, key, 50), Candidate: candidatePercentiles.TTLBPercentile(category, key, 50)},
+		{Category: category, Key: key, Metric: "ttlb_p95", Baseline: baselinePercentiles.TTLBPercentile(category, key, 95), Candidate: candidatePercentiles.TTLBPercentile(category, key, 95)},
+		{Category: category, Key: key, Metric: "ttlb_p99", Baseline: baselinePercentiles.TTLBPercentile(category, key, 99), Candidate: candidatePercentiles.TTLBPercentile(category, key, 99)},
+	}
+}
+
+func CompareMetricRowsForAggregates(category string, key string, baseline *AggregateStats, baselinePercentiles *PercentileIndex, candidate *AggregateStats, candidatePercentiles *PercentileIndex) []*CompareMetricRow {
+	rows := CompareMetricRowsForKeys(category, key, &ColumnSummary{
+		Key:                        key,
+		NumberOfMatchingRequests:   baseline.NumberOfMatchingRequests,
+		NumberOfSuccessfulRequests: baseline.NumberOfSuccessfulRequests,
+		TimeToFirstByteStatistics:  baseline.TimeToFirstByteStatistics,
+		TimeToLastByteStatistics:   baseline.TimeToLastByteStatistics,
+	}, baselinePercentiles, &ColumnSummary{
+		Key:                        key,
+		NumberOfMatchingRequests:   candidate.NumberOfMatchingRequests,
+		NumberOfSuccessfulRequests: candidate.NumberOfSuccessfulRequests,
+		TimeToFirstByteStatistics:  candidate.TimeToFirstByteStatistics,
+		TimeToLastByteStatistics:   candidate.TimeToLastByteStatistics,
+	}, candidatePercentiles)
+
+	rows = append(rows, &CompareMetricRow{Category: category, Key: key, Metric: "avg_tps", Baseline: baseline.AverageTPSRate, Candidate: candidate.AverageTPSRate})
+
+	return rows
+}
+
+func FailureRatePercent(totalRequests uint, successfulRequests uint) float64 {
+	if totalRequests == 0 {
+		return 0
+	}
+
+	failedRequests := totalRequests - successfulRequests
+
+	return float64(failedRequests) / float64(totalRequests) * 100
+}
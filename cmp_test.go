@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompareMetricRowAbsoluteAndPercentDelta(t *testing.T) {
+	row := &CompareMetricRow{Baseline: 100, Candidate: 125}
+
+	if got := row.AbsoluteDelta(); got != 25 {
+		t.Errorf("expected absolute delta 25, got %v", got)
+	}
+	if got := row.PercentDelta(); got != 25 {
+		t.Errorf("expected percent delta 25, got %v", got)
+	}
+}
+
+func TestCompareMetricRowPercentDeltaFromZeroBaseline(t *testing.T) {
+	unchanged := &CompareMetricRow{Baseline: 0, Candidate: 0}
+	if got := unchanged.PercentDelta(); got != 0 {
+		t.Errorf("expected 0/0 percent delta to be 0, got %v", got)
+	}
+
+	introduced := &CompareMetricRow{Baseline: 0, Candidate: 5}
+	if got := introduced.PercentDelta(); !math.IsInf(got, 1) {
+		t.Errorf("expected a nonzero candidate against a zero baseline to be +Inf, got %v", got)
+	}
+}
+
+func TestIsALatencyRegressionOnlyFlagsLatencyMetricsOverThreshold(t *testing.T) {
+	regressed := &CompareMetricRow{Metric: "ttfb_p95", Baseline: 100, Candidate: 150}
+	if !regressed.IsALatencyRegression(25) {
+		t.Error("expected a 50% increase in ttfb_p95 to exceed a 25% threshold")
+	}
+
+	withinThreshold := &CompareMetricRow{Metric: "ttfb_p95", Baseline: 100, Candidate: 110}
+	if withinThreshold.IsALatencyRegression(25) {
+		t.Error("expected a 10% increase to not exceed a 25% threshold")
+	}
+
+	nonLatencyMetric := &CompareMetricRow{Metric: "count", Baseline: 100, Candidate: 200}
+	if nonLatencyMetric.IsALatencyRegression(25) {
+		t.Error("expected a non-latency metric to never be flagged as a latency regression")
+	}
+
+	onlyInCandidate := &CompareMetricRow{Metric: "ttlb_p99", Baseline: 0, Candidate: 500, OnlyInCandidate: true}
+	if onlyInCandidate.IsALatencyRegression(25) {
+		t.Error("expected a row present only in the candidate to not be flagged as a regression")
+	}
+}
+
+func TestHasALatencyRegressionExceedingScansAllRows(t *testing.T) {
+	report := &CompareReport{
+		Rows: []*CompareMetricRow{
+			{Metric: "count", Baseline: 100, Candidate: 100},
+			{Metric: "ttfb_p95", Baseline: 100, Candidate: 200},
+		},
+	}
+
+	if !report.HasALatencyRegressionExceeding(50) {
+		t.Error("expected the ttfb_p95 row's 100% increase to exceed a 50% threshold")
+	}
+	if report.HasALatencyRegressionExceeding(150) {
+		t.Error("expected a 100% increase to not exceed a 150% threshold")
+	}
+}
+
+func TestFailureRatePercent(t *testing.T) {
+	if got := FailureRatePercent(0, 0); got != 0 {
+		t.Errorf("expected 0 total requests to report 0%% failure rate, got %v", got)
+	}
+
+	if got := FailureRatePercent(4, 3); got != 25 {
+		t.Errorf("expected 1 failure out of 4 to be 25%%, got %v", got)
+	}
+}
+
+func TestCompareSummarizersReportsNoDeltaForIdenticalRuns(t *testing.T) {
+	path := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n"+
+		"0,100,80,true,200,GET /a,10,100,2\n"+
+		"1000,120,90,true,200,GET /a,10,100,2\n")
+
+	baselineSummarizer, baselinePercentiles, err := SummarizerForJtlFileAtPath(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	candidateSummarizer, candidatePercentiles, err := SummarizerForJtlFileAtPath(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	report := CompareSummarizers(baselineSummarizer, baselinePercentiles, candidateSummarizer, candidatePercentiles)
+
+	if len(report.Rows) == 0 {
+		t.Fatal("expected a non-empty comparison report")
+	}
+
+	for _, row := range report.Rows {
+		if row.OnlyInBaseline || row.OnlyInCandidate {
+			t.Errorf("identical runs should have no baseline/candidate-only rows, got one for (%s/%s/%s)", row.Category, row.Key, row.Metric)
+		}
+		if row.AbsoluteDelta() != 0 {
+			t.Errorf("expected no delta for metric (%s) on identical runs, got baseline %v candidate %v", row.Metric, row.Baseline, row.Candidate)
+		}
+	}
+}
+
+func TestCompareSummarizersFlagsKeysOnlyPresentInOneRun(t *testing.T) {
+	baselinePath := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n"+
+		"0,100,80,true,200,GET /a,10,100,2\n")
+
+	candidatePath := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n"+
+		"0,100,80,true,200,GET /b,10,100,2\n")
+
+	baselineSummarizer, baselinePercentiles, err := SummarizerForJtlFileAtPath(baselinePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	candidateSummarizer, candidatePercentiles, err := SummarizerForJtlFileAtPath(candidatePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	report := CompareSummarizers(baselineSummarizer, baselinePercentiles, candidateSummarizer, candidatePercentiles)
+
+	foundOnlyInBaseline := false
+	foundOnlyInCandidate := false
+
+	for _, row := range report.Rows {
+		if row.Category == "method+uripath" && row.Key == "GET /a" && row.OnlyInBaseline {
+			foundOnlyInBaseline = true
+		}
+		if row.Category == "method+uripath" && row.Key == "GET /b" && row.OnlyInCandidate {
+			foundOnlyInCandidate = true
+		}
+	}
+
+	if !foundOnlyInBaseline {
+		t.Error("expected a method+uripath row for (GET /a) flagged OnlyInBaseline")
+	}
+	if !foundOnlyInCandidate {
+		t.Error("expected a method+uripath row for (GET /b) flagged OnlyInCandidate")
+	}
+}
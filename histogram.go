@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultHistogramSignificantFigures is the precision used when a caller does not configure one
+// explicitly, matching HdrHistogram's own conventional default.
+const DefaultHistogramSignificantFigures = 3
+
+// HistogramBucket is one row of a cumulative percentile distribution, in the shape written to
+// .hgrm files and consumed for Prometheus histogram buckets.
+type HistogramBucket struct {
+	ValueAtOrBelow  float64
+	Percentile      float64
+	CumulativeCount uint64
+}
+
+// Histogram is a log-linear, bounded-memory latency histogram in the style of HdrHistogram: each
+// bucket covers the range 2^exponent * (1 + subBucket/2^precision), where precision is derived
+// from SignificantFigures, so recording a value costs O(1) regardless of how many values have
+// been seen.
+type Histogram struct {
+	SignificantFigures int
+
+	precisionBits uint
+	counts        map[int64]uint64
+	totalCount    uint64
+	minimum       float64
+	maximum       float64
+}
+
+func NewHistogram(significantFigures int) *Histogram {
+	if significantFigures <= 0 {
+		significantFigures = DefaultHistogramSignificantFigures
+	}
+
+	precisionBits := uint(math.Ceil(math.Log2(math.Pow(10, float64(significantFigures)))))
+
+	return &Histogram{
+		SignificantFigures: significantFigures,
+		precisionBits:      precisionBits,
+		counts:             make(map[int64]uint64),
+	}
+}
+
+func (h *Histogram) bucketKey(value float64) int64 {
+	if value < 1 {
+		value = 1
+	}
+
+	exponent := int64(math.Floor(math.Log2(value)))
+	base := math.Pow(2, float64(exponent))
+	subBucket := int64((value/base - 1) * math.Pow(2, float64(h.precisionBits)))
+
+	return exponent<<32 | subBucket
+}
+
+func (h *Histogram) bucketValue(key int64) float64 {
+	exponent := key >> 32
+	subBucket := key & 0xFFFFFFFF
+
+	return math.Pow(2, float64(exponent)) * (1 + float64(subBucket)/math.Pow(2, float64(h.precisionBits)))
+}
+
+func (h *Histogram) RecordValue(value float64) {
+	h.counts[h.bucketKey(value)]++
+	h.totalCount++
+
+	if h.totalCount == 1 || value < h.minimum {
+		h.minimum = value
+	}
+	if value > h.maximum {
+		h.maximum = value
+	}
+}
+
+type bucketEntry struct {
+	key   int64
+	value float64
+	count uint64
+}
+
+func (h *Histogram) sortedBuckets() []bucketEntry {
+	entries := make([]bucketEntry, 0, len(h.counts))
+	for key, count := range h.counts {
+		entries = append(entries, bucketEntry{key: key, value: h.bucketValue(key), count: count})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].value < entries[j].value })
+
+	return entries
+}
+
+// ValueAtPercentile returns the value of the smallest bucket whose cumulative count reaches the
+// given percentile (0-100).
+func (h *Histogram) ValueAtPercentile(percentile float64) float64 {
+	if h.totalCount == 0 {
+		return 0
+	}
+
+	targetCount := uint64(math.Ceil(percentile / 100 * float64(h.totalCount)))
+	if targetCount < 1 {
+		targetCount = 1
+	}
+
+	cumulative := uint64(0)
+	for _, entry := range h.sortedBuckets() {
+		cumulative += entry.count
+		if cumulative >= targetCount {
+			return entry.value
+		}
+	}
+
+	return h.maximum
+}
+
+// PercentileDistribution returns the full cumulative distribution, one row per occupied bucket,
+// in ascending value order, suitable for writing a .hgrm file or Prometheus histogram buckets.
+func (h *Histogram) PercentileDistribution() []HistogramBucket {
+	if h.totalCount == 0 {
+		return nil
+	}
+
+	entries := h.sortedBuckets()
+	distribution := make([]HistogramBucket, 0, len(entries))
+	cumulative := uint64(0)
+
+	for _, entry := range entries {
+		cumulative += entry.count
+		distribution = append(distribution, HistogramBucket{
+			ValueAtOrBelow:  entry.value,
+			Percentile:      float64(cumulative) / float64(h.totalCount),
+			CumulativeCount: cumulative,
+		})
+	}
+
+	return distribution
+}
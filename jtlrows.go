@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// JtlRow is one decoded row of a JMeter JTL CSV file: the handful of fields the stats engine
+// actually needs, pulled out of the standard results-file column layout (timeStamp, elapsed,
+// label, responseCode, success, bytes, sentBytes, URL, Latency, allThreads, ...).
+type JtlRow struct {
+	TimestampAsUnixEpochMs  int64
+	TimeToFirstByteMs       float64
+	TimeToLastByteMs        float64
+	Success                 bool
+	ResponseCode            string
+	MethodAndURIPath        string
+	RequestBodySizeInBytes  int64
+	ResponseBytesReceived   int64
+	ConcurrentThreadsActive int64
+}
+
+// JtlRowError describes one CSV line that could not be decoded, in the same shape as
+// jtl.CsvDataRowError so callers can log it the same way.
+type JtlRowError struct {
+	LineNumber int
+	Error      string
+}
+
+// ReadJtlRows decodes a JMeter JTL CSV file header-first (so column order doesn't matter) and
+// invokes onRow for each valid data row as it is read, so a caller never has to hold the whole
+// file in memory at once.
+func ReadJtlRows(r io.Reader, onRow func(JtlRow)) ([]*JtlRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("on attempt to read JTL CSV header: %s", err.Error())
+	}
+
+	columnIndex := make(map[string]int, len(header))
+	for i, name := range header {
+		columnIndex[name] = i
+	}
+
+	rowErrors := []*JtlRowError{}
+	lineNumber := 1
+
+	for {
+		lineNumber++
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, &JtlRowError{LineNumber: lineNumber, Error: err.Error()})
+			continue
+		}
+
+		row, err := decodeJtlRow(record, columnIndex)
+		if err != nil {
+			rowErrors = append(rowErrors, &JtlRowError{LineNumber: lineNumber, Error: err.Error()})
+			continue
+		}
+
+		onRow(row)
+	}
+
+	return rowErrors, nil
+}
+
+func decodeJtlRow(record []string, columnIndex map[string]int) (JtlRow, error) {
+	field := func(name string) (string, bool) {
+		i, ok := columnIndex[name]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		return record[i], true
+	}
+
+	timestampText, ok := field("timeStamp")
+	if !ok {
+		return JtlRow{}, fmt.Errorf("missing timeStamp column")
+	}
+
+	timestamp, err := strconv.ParseInt(timestampText, 10, 64)
+	if err != nil {
+		return JtlRow{}, fmt.Errorf("invalid timeStamp (%s): %s", timestampText, err.Error())
+	}
+
+	row := JtlRow{TimestampAsUnixEpochMs: timestamp, Success: true}
+
+	if elapsedText, ok := field("elapsed"); ok {
+		if elapsed, err := strconv.ParseFloat(elapsedText, 64); err == nil {
+			row.TimeToLastByteMs = elapsed
+		}
+	}
+
+	if latencyText, ok := field("Latency"); ok {
+		if latency, err := strconv.ParseFloat(latencyText, 64); err == nil {
+			row.TimeToFirstByteMs = latency
+		}
+	} else {
+		row.TimeToFirstByteMs = row.TimeToLastByteMs
+	}
+
+	if successText, ok := field("success"); ok {
+		row.Success = successText == "true"
+	}
+
+	if responseCode, ok := field("responseCode"); ok {
+		row.ResponseCode = responseCode
+	}
+
+	if uriPath, ok := field("URL"); ok && uriPath != "" {
+		row.MethodAndURIPath = uriPath
+	} else if label, ok := field("label"); ok {
+		row.MethodAndURIPath = label
+	}
+
+	if sentBytesText, ok := field("sentBytes"); ok {
+		if sentBytes, err := strconv.ParseInt(sentBytesText, 10, 64); err == nil {
+			row.RequestBodySizeInBytes = sentBytes
+		}
+	}
+
+	if bytesText, ok := field("bytes"); ok {
+		if bytesReceived, err := strconv.ParseInt(bytesText, 10, 64); err == nil {
+			row.ResponseBytesReceived = bytesReceived
+		}
+	}
+
+	if allThreadsText, ok := field("allThreads"); ok {
+		if allThreads, err := strconv.ParseInt(allThreadsText, 10, 64); err == nil {
+			row.ConcurrentThreadsActive = allThreads
+		}
+	}
+
+	return row, nil
+}
@@ -5,34 +5,58 @@ import (
 	"flag"
 	"fmt"
 	"os"
-
-	"github.com/blorticus-go/jtl"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // JtlStatsProcessor /path/to/jtl/file -o /path/to/summary/output/file -t /directory/to/write/timestamp/files
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cmp" {
+		RunCompareMode(os.Args[2:])
+		return
+	}
+
 	cliArgs, err := ProcessCommandLineOptions()
 	DieIfError(err)
 
-	jtlFile, err := os.Open(cliArgs.PathToJtlSourceCsvFile)
+	summarizer, rowsThatCannotBeProcessed, err := NewSummarizerFromCsv(cliArgs.PathToJtlSourceCsvFile, cliArgs.StreamingMode, int(cliArgs.ReservoirSize), cliArgs.HistogramSignificantFigures)
 	DieIfError(err)
 
-	jtlDataSource, dataRowsThatCannotBeProcessed, fatalError := jtl.NewDataSourceFromCsv(jtlFile)
-	DieIfError(fatalError)
+	LogAnyRowsThatCannotBeProcessed(rowsThatCannotBeProcessed)
 
-	LogAnyRowsThatCannotBeProcessed(dataRowsThatCannotBeProcessed)
+	percentileIndex := summarizer.PercentileIndex()
 
-	summarizer := jtl.NewSummarizerForDataSource(jtlDataSource)
-	err = summarizer.PreComputeAggregateSummaryAndSummariesForColumns(jtl.Column.RequestURL, jtl.Column.ResponseCodeOrErrorMessage, jtl.Column.RequestBodySizeInBytes, jtl.Column.ResponseBytesReceived)
-	DieIfError(err)
+	if cliArgs.TimeSeriesInterval > 0 {
+		err := WriteTimeSeriesOutputs(cliArgs)
+		DieIfError(err)
+	}
 
 	if cliArgs.PathToDirectoryForTimestampFiles != "" {
 		err := WriteTimestampFiles(cliArgs.PathToDirectoryForTimestampFiles, summarizer)
 		DieIfError(err)
 	}
 
-	summaryText := GenerateSummaryOutputText(summarizer)
+	WarnIfPercentilesAreSampled(cliArgs)
+
+	if cliArgs.PathToHdrHistogramOutputDirectory != "" {
+		err := WriteHdrHistogramFiles(cliArgs.PathToHdrHistogramOutputDirectory, summarizer, percentileIndex)
+		DieIfError(err)
+	}
+
+	if cliArgs.PathToPrometheusOutputFile != "" {
+		err := WritePrometheusExpositionFile(cliArgs.PathToPrometheusOutputFile, summarizer, percentileIndex)
+		DieIfError(err)
+	}
+
+	if cliArgs.PushgatewayURL != "" {
+		err := PushPrometheusExpositionToGateway(cliArgs.PushgatewayURL, cliArgs.PushgatewayJobName, summarizer, percentileIndex)
+		DieIfError(err)
+	}
+
+	summaryText := GenerateSummaryOutputText(summarizer, cliArgs.RequestedPercentiles, percentileIndex)
 
 	if cliArgs.PathToSummaryOutputCsvFile != "" {
 		err := WriteSummaryToFile(cliArgs.PathToSummaryOutputCsvFile, summaryText)
@@ -40,6 +64,13 @@ func main() {
 	} else {
 		fmt.Print(summaryText)
 	}
+
+	anySloWasViolated, err := EvaluateAndReportSlos(cliArgs, summarizer, percentileIndex)
+	DieIfError(err)
+
+	if anySloWasViolated {
+		os.Exit(3)
+	}
 }
 
 func DieIfError(err error) {
@@ -50,16 +81,47 @@ func DieIfError(err error) {
 }
 
 type CommandLineArguments struct {
-	PathToJtlSourceCsvFile           string
-	PathToSummaryOutputCsvFile       string
-	PathToDirectoryForTimestampFiles string
+	PathToJtlSourceCsvFile            string
+	PathToSummaryOutputCsvFile        string
+	PathToDirectoryForTimestampFiles  string
+	StreamingMode                     bool
+	ReservoirSize                     uint
+	RequestedPercentiles              []float64
+	PathToHdrHistogramOutputDirectory string
+	PathToPrometheusOutputFile        string
+	PushgatewayURL                    string
+	PushgatewayJobName                string
+	TimeSeriesInterval                time.Duration
+	PathToTimeSeriesCsvFile           string
+	PathToRrdFile                     string
+	SloExpressions                    SloExpressionList
+	PathToSloFile                     string
+	PathToSloOutputFile               string
+	HistogramSignificantFigures       int
 }
 
 func ProcessCommandLineOptions() (*CommandLineArguments, error) {
 	args := &CommandLineArguments{}
 
+	var commaSeparatedPercentiles string
+	var intervalString string
+
 	flag.StringVar(&args.PathToSummaryOutputCsvFile, "o", "", "Path to file for summary output")
 	flag.StringVar(&args.PathToDirectoryForTimestampFiles, "t", "", "Path to directory into which timestamp files should be written")
+	flag.BoolVar(&args.StreamingMode, "stream", false, "Process the source CSV one row at a time using fixed-memory sketches instead of buffering the full file")
+	flag.UintVar(&args.ReservoirSize, "reservoir-size", 10000, "Number of samples to retain per column/key reservoir when -stream is given; percentiles become approximate above this")
+	flag.StringVar(&commaSeparatedPercentiles, "p", "", "Comma-separated list of additional latency percentiles to report (e.g. -p 50,75,90,95,99,99.9)")
+	flag.StringVar(&args.PathToHdrHistogramOutputDirectory, "hdr", "", "Path to directory into which per-category TTFB/TTLB .hgrm histogram files should be written")
+	flag.IntVar(&args.HistogramSignificantFigures, "hdr-sigfigs", DefaultHistogramSignificantFigures, "Significant figures of precision for the HdrHistogram-backed -p/-hdr percentiles")
+	flag.StringVar(&args.PathToPrometheusOutputFile, "prom", "", "Path to file for Prometheus/OpenMetrics text exposition output")
+	flag.StringVar(&args.PushgatewayURL, "pushgateway", "", "Base URL of a Prometheus Pushgateway to which results should be POSTed")
+	flag.StringVar(&args.PushgatewayJobName, "job", "jtl-stats-processor", "Job name to use when pushing to -pushgateway")
+	flag.StringVar(&intervalString, "interval", "", "Bucket interval (e.g. 1s, 10s, 1m) for per-interval time-series output; requires -ts-csv and/or -rrd")
+	flag.StringVar(&args.PathToTimeSeriesCsvFile, "ts-csv", "", "Path to wide CSV file for per-interval time-series output")
+	flag.StringVar(&args.PathToRrdFile, "rrd", "", "Path to RRD file for per-interval time-series output; updated in place if it already exists")
+	flag.Var(&args.SloExpressions, "slo", "Comma-separated SLO threshold expressions (e.g. -slo ttfb.p95<=250ms,error_rate<=0.5%,tps>=500); repeatable")
+	flag.StringVar(&args.PathToSloFile, "slo-file", "", "Path to a YAML file of SLOs, including per-key scoped thresholds")
+	flag.StringVar(&args.PathToSloOutputFile, "slo-out", "", "Path to file for the JSON SLO violation report (default: stderr)")
 
 	flag.Parse()
 
@@ -72,16 +134,60 @@ func ProcessCommandLineOptions() (*CommandLineArguments, error) {
 
 	args.PathToJtlSourceCsvFile = flag.Arg(0)
 
+	percentiles, err := ParseCommaSeparatedPercentiles(commaSeparatedPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	args.RequestedPercentiles = percentiles
+
+	if intervalString != "" {
+		interval, err := time.ParseDuration(intervalString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -interval (%s): %s", intervalString, err.Error())
+		}
+
+		args.TimeSeriesInterval = interval
+	}
+
 	return args, nil
 }
 
-func LogAnyRowsThatCannotBeProcessed(descriptors []*jtl.CsvDataRowError) {
+func ParseCommaSeparatedPercentiles(commaSeparatedPercentiles string) ([]float64, error) {
+	if commaSeparatedPercentiles == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(commaSeparatedPercentiles, ",")
+	percentiles := make([]float64, 0, len(fields))
+
+	for _, field := range fields {
+		percentile, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile (%s) in -p: %s", field, err.Error())
+		}
+
+		percentiles = append(percentiles, percentile)
+	}
+
+	return percentiles, nil
+}
+
+func LogAnyRowsThatCannotBeProcessed(descriptors []*JtlRowError) {
 	for _, rowError := range descriptors {
 		fmt.Fprintf(os.Stderr, "[WARNING] ignoring CSV source file line (%d): %s\n", rowError.LineNumber, rowError.Error)
 	}
 }
 
-func WriteTimestampFiles(pathToTimestampFilesDirectory string, summarizer *jtl.Summarizer) error {
+// WarnIfPercentilesAreSampled prints a notice to stderr when -stream was given, since in that
+// mode the 5th/95th percentile columns come from a reservoir sample rather than an exact sort of
+// every observed value.
+func WarnIfPercentilesAreSampled(cliArgs *CommandLineArguments) {
+	if cliArgs.StreamingMode {
+		fmt.Fprintln(os.Stderr, "[NOTICE] percentile values were computed from a reservoir sample (-stream) and are approximate")
+	}
+}
+
+func WriteTimestampFiles(pathToTimestampFilesDirectory string, summarizer *Summarizer) error {
 	startTimestampFile, err := os.Create(pathToTimestampFilesDirectory + "/start.ts")
 	if err != nil {
 		return fmt.Errorf("on attempt to write to (%s)/start.ts: %s", pathToTimestampFilesDirectory, err.Error())
@@ -110,56 +216,151 @@ func WriteTimestampFiles(pathToTimestampFilesDirectory string, summarizer *jtl.S
 	return nil
 }
 
-func GenerateSummaryOutputText(summarizer *jtl.Summarizer) string {
+func WriteHdrHistogramFiles(pathToOutputDirectory string, summarizer *Summarizer, percentileIndex *PercentileIndex) error {
+	if err := WriteHdrHistogramFilePair(pathToOutputDirectory, "aggregate", percentileIndex, "aggregate", ""); err != nil {
+		return err
+	}
+
+	statsByURLs, _ := summarizer.SummariesForTheColumn(ColumnMethodAndURIPath)
+	for _, s := range statsByURLs {
+		baseName := "method+uripath-" + SanitizeForUseAsAFileName(s.KeyAsAString())
+		if err := WriteHdrHistogramFilePair(pathToOutputDirectory, baseName, percentileIndex, "method+uripath", s.KeyAsAString()); err != nil {
+			return err
+		}
+	}
+
+	statsByResponseCode, _ := summarizer.SummariesForTheColumn(ColumnResponseCode)
+	for _, s := range statsByResponseCode {
+		baseName := "responseCode-" + SanitizeForUseAsAFileName(s.KeyAsAString())
+		if err := WriteHdrHistogramFilePair(pathToOutputDirectory, baseName, percentileIndex, "responseCode", s.KeyAsAString()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteHdrHistogramFilePair(pathToOutputDirectory string, baseName string, percentileIndex *PercentileIndex, category string, key string) error {
+	if err := WriteHdrHistogramFile(filepath.Join(pathToOutputDirectory, baseName+"-ttfb.hgrm"), percentileIndex.TTFBDistribution(category, key)); err != nil {
+		return err
+	}
+
+	return WriteHdrHistogramFile(filepath.Join(pathToOutputDirectory, baseName+"-ttlb.hgrm"), percentileIndex.TTLBDistribution(category, key))
+}
+
+func WriteHdrHistogramFile(pathToOutputFile string, distribution []HistogramBucket) error {
+	outputFile, err := os.Create(pathToOutputFile)
+	if err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+	defer outputFile.Close()
+
+	fmt.Fprintln(outputFile, "       Value     Percentile TotalCount 1/(1-Percentile)")
+
+	for _, bucket := range distribution {
+		inverse := "Inf"
+		if bucket.Percentile < 1 {
+			inverse = fmt.Sprintf("%0.2f", 1/(1-bucket.Percentile))
+		}
+
+		if _, err := fmt.Fprintf(outputFile, "%12.3f %14.9f %10d %14s\n", bucket.ValueAtOrBelow, bucket.Percentile, bucket.CumulativeCount, inverse); err != nil {
+			return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func SanitizeForUseAsAFileName(s string) string {
+	r := strings.NewReplacer("/", "_", " ", "_", "\\", "_", ":", "_")
+	return r.Replace(s)
+}
+
+func GenerateSummaryOutputText(summarizer *Summarizer, requestedPercentiles []float64, percentileIndex *PercentileIndex) string {
 	textBuffer := &bytes.Buffer{}
 
 	textBuffer.WriteString("Category,Key,Total Requests Made,Failed Requests," +
 		"TTFB Mean,TTFB Median,TTFB Stdev,TTFB Minimum,TTFB Maximum,TTFB 5th Percentile,TTFB 95th Percentile," +
 		"TTLB Mean,TTLB Median,TTLB Stdev,TTLB Minimum,TTLB Maximum,TTLB 5th Percentile,TTLB 95th Percentile," +
-		"Overall TPS\n")
+		"Overall TPS," +
+		"Moving TPS Mean,Moving TPS Median,Moving TPS Stdev,Moving TPS Minimum,Moving TPS Maximum,Moving TPS 5th Percentile,Moving TPS 95th Percentile")
+	textBuffer.WriteString(PercentileColumnHeadings(requestedPercentiles))
+	textBuffer.WriteRune('\n')
 
 	aggregateStats, _ := summarizer.AggregateSummary()
 
 	ttfb := aggregateStats.TimeToFirstByteStatistics
 	ttlb := aggregateStats.TimeToLastByteStatistics
 
-	textBuffer.WriteString(fmt.Sprintf("Aggregate,,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f\n",
+	movingTPS := aggregateStats.MovingTPSStatistics
+
+	textBuffer.WriteString(fmt.Sprintf("Aggregate,,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f",
 		aggregateStats.NumberOfMatchingRequests, aggregateStats.NumberOfMatchingRequests-uint(aggregateStats.NumberOfSuccessfulRequests),
 		ttfb.Mean, ttfb.Mean, ttfb.PopulationStandardDeviation, ttfb.Minimum, ttfb.Maximum, ttfb.ValueAt5thPercentile, ttfb.ValueAt95thPercentile,
 		ttlb.Mean, ttlb.Mean, ttlb.PopulationStandardDeviation, ttlb.Minimum, ttlb.Maximum, ttlb.ValueAt5thPercentile, ttlb.ValueAt95thPercentile,
-		aggregateStats.AverageTPSRate))
+		aggregateStats.AverageTPSRate,
+		movingTPS.Mean, movingTPS.Mean, movingTPS.PopulationStandardDeviation, movingTPS.Minimum, movingTPS.Maximum, movingTPS.ValueAt5thPercentile, movingTPS.ValueAt95thPercentile))
+	textBuffer.WriteString(PercentileColumnValues("aggregate", "", percentileIndex, requestedPercentiles))
+	textBuffer.WriteRune('\n')
 
-	statsByURLs, _ := summarizer.SummariesForTheColumn(jtl.Column.ResultLabel)
+	statsByURLs, _ := summarizer.SummariesForTheColumn(ColumnMethodAndURIPath)
 	for _, s := range statsByURLs {
-		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "method+uripath"))
+		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "method+uripath", percentileIndex, requestedPercentiles))
 	}
 
-	statsByResponseCode, _ := summarizer.SummariesForTheColumn(jtl.Column.ResponseCodeOrErrorMessage)
+	statsByResponseCode, _ := summarizer.SummariesForTheColumn(ColumnResponseCode)
 	for _, s := range statsByResponseCode {
-		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "responseCode"))
+		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "responseCode", percentileIndex, requestedPercentiles))
 	}
 
-	statsByResponseSize, _ := summarizer.SummariesForTheColumn(jtl.Column.ResponseBytesReceived)
+	statsByResponseSize, _ := summarizer.SummariesForTheColumn(ColumnResponseSizeInBytes)
 	for _, s := range statsByResponseSize {
-		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "responseSizeInBytes"))
+		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "responseSizeInBytes", percentileIndex, requestedPercentiles))
 	}
 
-	statsByRequestSize, _ := summarizer.SummariesForTheColumn(jtl.Column.RequestBodySizeInBytes)
+	statsByRequestSize, _ := summarizer.SummariesForTheColumn(ColumnRequestBodySizeInBytes)
 	for _, s := range statsByRequestSize {
-		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "requestBodyInBytes"))
+		textBuffer.WriteString(GenerateSummaryTextForColumnValue(s, "requestBodyInBytes", percentileIndex, requestedPercentiles))
 	}
 
 	return textBuffer.String()
 }
 
-func GenerateSummaryTextForColumnValue(s *jtl.ColumnUniqueValueSummary, labelForCategory string) string {
+func GenerateSummaryTextForColumnValue(s *ColumnSummary, labelForCategory string, percentileIndex *PercentileIndex, requestedPercentiles []float64) string {
 	ttfb := s.TimeToFirstByteStatistics
 	ttlb := s.TimeToLastByteStatistics
 
-	return fmt.Sprintf("%s,%s,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,-\n",
+	row := fmt.Sprintf("%s,%s,%d,%d,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,-,-,-,-,-,-,-,-",
 		labelForCategory, s.KeyAsAString(), s.NumberOfMatchingRequests, s.NumberOfMatchingRequests-uint(s.NumberOfSuccessfulRequests),
 		ttfb.Mean, ttfb.Mean, ttfb.PopulationStandardDeviation, ttfb.Minimum, ttfb.Maximum, ttfb.ValueAt5thPercentile, ttfb.ValueAt95thPercentile,
 		ttlb.Mean, ttlb.Mean, ttlb.PopulationStandardDeviation, ttlb.Minimum, ttlb.Maximum, ttlb.ValueAt5thPercentile, ttlb.ValueAt95thPercentile)
+
+	return row + PercentileColumnValues(labelForCategory, s.KeyAsAString(), percentileIndex, requestedPercentiles) + "\n"
+}
+
+// PercentileColumnHeadings returns the ",TTFB Pnn,TTLB Pnn,..." header suffix for each
+// percentile requested via -p, in the order given.
+func PercentileColumnHeadings(requestedPercentiles []float64) string {
+	headings := &bytes.Buffer{}
+
+	for _, percentile := range requestedPercentiles {
+		headings.WriteString(fmt.Sprintf(",TTFB P%v,TTLB P%v", percentile, percentile))
+	}
+
+	return headings.String()
+}
+
+// PercentileColumnValues returns the corresponding ",value,value,..." row suffix, reading each
+// percentile for category/key from the HdrHistogram-backed PercentileIndex.
+func PercentileColumnValues(category string, key string, percentileIndex *PercentileIndex, requestedPercentiles []float64) string {
+	values := &bytes.Buffer{}
+
+	for _, percentile := range requestedPercentiles {
+		values.WriteString(fmt.Sprintf(",%0.2f,%0.2f",
+			percentileIndex.TTFBPercentile(category, key, percentile), percentileIndex.TTLBPercentile(category, key, percentile)))
+	}
+
+	return values.String()
 }
 
 func WriteSummaryToFile(pathToOutputFile string, summaryText string) error {
@@ -0,0 +1,67 @@
+package main
+
+// PercentileIndex holds a TTFB/TTLB Histogram per category/key. The Histograms themselves are
+// built by Summarizer during its single pass over the source rows (see Summarizer.PercentileIndex);
+// this type only indexes them by category/key for lookup. jtl.Statistics only ever exposed
+// Mean/Median/Stdev/Min/Max/5th/95th, so any percentile beyond those (the -p/-hdr output) is
+// served from here instead of assumed to exist upstream.
+type PercentileIndex struct {
+	ttfb map[string]*Histogram
+	ttlb map[string]*Histogram
+}
+
+func newPercentileIndex() *PercentileIndex {
+	return &PercentileIndex{
+		ttfb: make(map[string]*Histogram),
+		ttlb: make(map[string]*Histogram),
+	}
+}
+
+func percentileIndexKey(category string, key string) string {
+	return category + "\x00" + key
+}
+
+// set indexes the already-built ttfb/ttlb Histograms for category/key.
+func (idx *PercentileIndex) set(category string, key string, ttfb *Histogram, ttlb *Histogram) {
+	indexKey := percentileIndexKey(category, key)
+	idx.ttfb[indexKey] = ttfb
+	idx.ttlb[indexKey] = ttlb
+}
+
+// TTFBPercentile returns the TTFB value at the given percentile for category/key, or 0 if no
+// rows were ever recorded under that category/key.
+func (idx *PercentileIndex) TTFBPercentile(category string, key string, percentile float64) float64 {
+	if h, ok := idx.ttfb[percentileIndexKey(category, key)]; ok {
+		return h.ValueAtPercentile(percentile)
+	}
+
+	return 0
+}
+
+// TTLBPercentile returns the TTLB value at the given percentile for category/key, or 0 if no
+// rows were ever recorded under that category/key.
+func (idx *PercentileIndex) TTLBPercentile(category string, key string, percentile float64) float64 {
+	if h, ok := idx.ttlb[percentileIndexKey(category, key)]; ok {
+		return h.ValueAtPercentile(percentile)
+	}
+
+	return 0
+}
+
+// TTFBDistribution returns the full cumulative TTFB distribution for category/key.
+func (idx *PercentileIndex) TTFBDistribution(category string, key string) []HistogramBucket {
+	if h, ok := idx.ttfb[percentileIndexKey(category, key)]; ok {
+		return h.PercentileDistribution()
+	}
+
+	return nil
+}
+
+// TTLBDistribution returns the full cumulative TTLB distribution for category/key.
+func (idx *PercentileIndex) TTLBDistribution(category string, key string) []HistogramBucket {
+	if h, ok := idx.ttlb[percentileIndexKey(category, key)]; ok {
+		return h.PercentileDistribution()
+	}
+
+	return nil
+}
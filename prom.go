@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// WritePrometheusExpositionFile writes the summarizer's results to pathToOutputFile in
+// Prometheus/OpenMetrics text exposition format.
+func WritePrometheusExpositionFile(pathToOutputFile string, summarizer *Summarizer, percentileIndex *PercentileIndex) error {
+	outputFile, err := os.Create(pathToOutputFile)
+	if err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+	defer outputFile.Close()
+
+	if _, err := outputFile.WriteString(BuildPrometheusExposition(summarizer, percentileIndex)); err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+
+	return nil
+}
+
+// PushPrometheusExpositionToGateway POSTs the summarizer's results to a Prometheus Pushgateway
+// at baseURL, grouped under the given job name.
+func PushPrometheusExpositionToGateway(baseURL string, jobName string, summarizer *Summarizer, percentileIndex *PercentileIndex) error {
+	pushURL := PushgatewayURL(baseURL, jobName)
+
+	response, err := http.Post(pushURL, "text/plain; version=0.0.4", strings.NewReader(BuildPrometheusExposition(summarizer, percentileIndex)))
+	if err != nil {
+		return fmt.Errorf("on attempt to push to pushgateway (%s): %s", pushURL, err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway (%s) returned status (%s)", pushURL, response.Status)
+	}
+
+	return nil
+}
+
+// PushgatewayURL builds the grouping-key push URL for jobName, escaping it as a URL path segment
+// (not a Prometheus label value: the two escaping rules differ, e.g. "/" and spaces are not
+// handled the same way, and using the wrong one here would mis-route or reject the push).
+func PushgatewayURL(baseURL string, jobName string) string {
+	return strings.TrimRight(baseURL, "/") + "/metrics/job/" + url.PathEscape(jobName)
+}
+
+// BuildPrometheusExposition renders the aggregate and per-key summaries as Prometheus text
+// exposition format metrics: jtl_requests_total, jtl_requests_failed_total, jtl_ttfb_seconds
+// (summary quantiles), jtl_ttlb_seconds (histogram buckets), and jtl_tps.
+func BuildPrometheusExposition(summarizer *Summarizer, percentileIndex *PercentileIndex) string {
+	textBuffer := &bytes.Buffer{}
+
+	textBuffer.WriteString("# HELP jtl_requests_total Total requests matching a category/key\n")
+	textBuffer.WriteString("# TYPE jtl_requests_total counter\n")
+	textBuffer.WriteString("# HELP jtl_requests_failed_total Requests matching a category/key that failed\n")
+	textBuffer.WriteString("# TYPE jtl_requests_failed_total counter\n")
+	textBuffer.WriteString("# HELP jtl_ttfb_seconds Time-to-first-byte quantiles, in seconds\n")
+	textBuffer.WriteString("# TYPE jtl_ttfb_seconds summary\n")
+	textBuffer.WriteString("# HELP jtl_ttlb_seconds Time-to-last-byte distribution, in seconds\n")
+	textBuffer.WriteString("# TYPE jtl_ttlb_seconds histogram\n")
+	textBuffer.WriteString("# HELP jtl_tps Average transactions per second over the run\n")
+	textBuffer.WriteString("# TYPE jtl_tps gauge\n")
+
+	aggregateStats, _ := summarizer.AggregateSummary()
+	WritePrometheusMetricsForOneKey(textBuffer, "aggregate", "",
+		aggregateStats.NumberOfMatchingRequests, uint(aggregateStats.NumberOfSuccessfulRequests),
+		aggregateStats.TimeToLastByteStatistics, percentileIndex)
+	fmt.Fprintf(textBuffer, "jtl_tps %v\n", aggregateStats.AverageTPSRate)
+
+	statsByURLs, _ := summarizer.SummariesForTheColumn(ColumnMethodAndURIPath)
+	for _, s := range statsByURLs {
+		WritePrometheusMetricsForOneKey(textBuffer, "method+uripath", s.KeyAsAString(),
+			s.NumberOfMatchingRequests, uint(s.NumberOfSuccessfulRequests), s.TimeToLastByteStatistics, percentileIndex)
+	}
+
+	statsByResponseCode, _ := summarizer.SummariesForTheColumn(ColumnResponseCode)
+	for _, s := range statsByResponseCode {
+		WritePrometheusMetricsForOneKey(textBuffer, "responseCode", s.KeyAsAString(),
+			s.NumberOfMatchingRequests, uint(s.NumberOfSuccessfulRequests), s.TimeToLastByteStatistics, percentileIndex)
+	}
+
+	return textBuffer.String()
+}
+
+func WritePrometheusMetricsForOneKey(textBuffer *bytes.Buffer, category string, key string, totalRequests uint, successfulRequests uint, ttlb *Statistics, percentileIndex *PercentileIndex) {
+	labels := PrometheusLabels(category, key)
+
+	fmt.Fprintf(textBuffer, "jtl_requests_total{%s} %d\n", labels, totalRequests)
+	fmt.Fprintf(textBuffer, "jtl_requests_failed_total{%s} %d\n", labels, totalRequests-successfulRequests)
+
+	for _, quantile := range []float64{0.5, 0.95, 0.99} {
+		fmt.Fprintf(textBuffer, "jtl_ttfb_seconds{%s,quantile=\"%v\"} %v\n", labels, quantile, percentileIndex.TTFBPercentile(category, key, quantile*100)/1000)
+	}
+
+	cumulativeCount := uint64(0)
+	for _, bucket := range percentileIndex.TTLBDistribution(category, key) {
+		cumulativeCount = bucket.CumulativeCount
+		fmt.Fprintf(textBuffer, "jtl_ttlb_seconds_bucket{%s,le=\"%v\"} %d\n", labels, bucket.ValueAtOrBelow/1000, bucket.CumulativeCount)
+	}
+	fmt.Fprintf(textBuffer, "jtl_ttlb_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, cumulativeCount)
+	fmt.Fprintf(textBuffer, "jtl_ttlb_seconds_sum{%s} %v\n", labels, ttlb.Mean*float64(totalRequests)/1000)
+	fmt.Fprintf(textBuffer, "jtl_ttlb_seconds_count{%s} %d\n", labels, totalRequests)
+}
+
+func PrometheusLabels(category string, key string) string {
+	if key == "" {
+		return fmt.Sprintf("category=%q", category)
+	}
+
+	return fmt.Sprintf("category=%q,key=%q", category, PrometheusLabelValueEscape(key))
+}
+
+// PrometheusLabelValueEscape escapes backslash, double-quote, and newline as required for a
+// Prometheus exposition format label value.
+func PrometheusLabelValueEscape(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
+	escaped = strings.ReplaceAll(escaped, "\n", `\n`)
+
+	return escaped
+}
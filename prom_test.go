@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestPushgatewayURLPathEscapesTheJobNameNotLabelEscapesIt(t *testing.T) {
+	got := PushgatewayURL("http://pushgateway:9091", "nightly/load-test run")
+	want := "http://pushgateway:9091/metrics/job/nightly%2Fload-test%20run"
+
+	if got != want {
+		t.Errorf("expected (%s), got (%s)", want, got)
+	}
+}
+
+func TestPushgatewayURLTrimsTrailingSlashesFromBaseURL(t *testing.T) {
+	got := PushgatewayURL("http://pushgateway:9091/", "jtl-stats-processor")
+	want := "http://pushgateway:9091/metrics/job/jtl-stats-processor"
+
+	if got != want {
+		t.Errorf("expected (%s), got (%s)", want, got)
+	}
+}
+
+func TestPrometheusLabelValueEscapeHandlesBackslashQuoteAndNewline(t *testing.T) {
+	got := PrometheusLabelValueEscape("a\\b\"c\nd")
+	want := `a\\b\"c\nd`
+
+	if got != want {
+		t.Errorf("expected (%s), got (%s)", want, got)
+	}
+}
+
+func TestPrometheusLabelsOmitsKeyWhenEmpty(t *testing.T) {
+	got := PrometheusLabels("aggregate", "")
+	want := `category="aggregate"`
+
+	if got != want {
+		t.Errorf("expected (%s), got (%s)", want, got)
+	}
+}
+
+func TestPrometheusLabelsEscapesTheKey(t *testing.T) {
+	// PrometheusLabels runs the key through PrometheusLabelValueEscape and then %q, which
+	// escapes backslash/quote a second time; this pins the existing (if doubly-escaped)
+	// behavior rather than asserting a single-escape form the code doesn't produce.
+	got := PrometheusLabels("method+uripath", `GET "odd" path`)
+	want := `category="method+uripath",key="GET \\\"odd\\\" path"`
+
+	if got != want {
+		t.Errorf("expected (%s), got (%s)", want, got)
+	}
+}
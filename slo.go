@@ -0,0 +1,328 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SloExpressionList accumulates repeated -slo flag occurrences, each a comma-separated list of
+// threshold expressions, e.g. "ttfb.p95<=250ms,ttlb.p99<=1s,error_rate<=0.5%,tps>=500".
+type SloExpressionList []string
+
+func (l *SloExpressionList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *SloExpressionList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// SloDefinition is one threshold to evaluate, optionally scoped to a single category/key.
+type SloDefinition struct {
+	Metric         string
+	Comparator     string
+	ThresholdValue float64
+	ThresholdText  string
+	Category       string
+	Key            string
+	Severity       string
+}
+
+// SloViolation is a single threshold that did not hold, in the shape emitted by -slo-out.
+type SloViolation struct {
+	Path      string  `json:"path"`
+	Observed  float64 `json:"observed"`
+	Threshold string  `json:"threshold"`
+	Severity  string  `json:"severity"`
+}
+
+var sloExpressionPattern = regexp.MustCompile(`^([a-zA-Z0-9_.]+)\s*(<=|>=|<|>|==)\s*(.+)$`)
+
+// ParseSloExpressions parses every comma-separated expression from every -slo flag occurrence
+// into unscoped (aggregate-wide) SLO definitions.
+func ParseSloExpressions(expressions []string) ([]*SloDefinition, error) {
+	defs := []*SloDefinition{}
+
+	for _, flagValue := range expressions {
+		for _, expression := range strings.Split(flagValue, ",") {
+			expression = strings.TrimSpace(expression)
+			if expression == "" {
+				continue
+			}
+
+			def, err := ParseOneSloExpression(expression)
+			if err != nil {
+				return nil, err
+			}
+
+			defs = append(defs, def)
+		}
+	}
+
+	return defs, nil
+}
+
+func ParseOneSloExpression(expression string) (*SloDefinition, error) {
+	matches := sloExpressionPattern.FindStringSubmatch(expression)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid -slo expression (%s)", expression)
+	}
+
+	thresholdValue, err := ParseSloThresholdValue(matches[3])
+	if err != nil {
+		return nil, fmt.Errorf("invalid -slo expression (%s): %s", expression, err.Error())
+	}
+
+	return &SloDefinition{
+		Metric:         matches[1],
+		Comparator:     matches[2],
+		ThresholdValue: thresholdValue,
+		ThresholdText:  matches[2] + matches[3],
+		Severity:       "critical",
+	}, nil
+}
+
+// ParseSloThresholdValue converts a threshold's right-hand side to the unit the corresponding
+// statistic is stored in: milliseconds for latency, percent for error_rate, and a raw count/rate
+// for everything else (e.g. tps).
+func ParseSloThresholdValue(text string) (float64, error) {
+	switch {
+	case strings.HasSuffix(text, "ms"):
+		return strconv.ParseFloat(strings.TrimSuffix(text, "ms"), 64)
+	case strings.HasSuffix(text, "s"):
+		seconds, err := strconv.ParseFloat(strings.TrimSuffix(text, "s"), 64)
+		return seconds * 1000, err
+	case strings.HasSuffix(text, "%"):
+		return strconv.ParseFloat(strings.TrimSuffix(text, "%"), 64)
+	default:
+		return strconv.ParseFloat(text, 64)
+	}
+}
+
+// SloFile is the structure of a -slo-file YAML document.
+type SloFile struct {
+	Slos []SloFileEntry `yaml:"slos"`
+}
+
+type SloFileEntry struct {
+	Metric    string    `yaml:"metric"`
+	Threshold string    `yaml:"threshold"`
+	Severity  string    `yaml:"severity"`
+	Scope     *SloScope `yaml:"scope"`
+}
+
+type SloScope struct {
+	Category string `yaml:"category"`
+	Key      string `yaml:"key"`
+}
+
+func LoadSloDefinitionsFromFile(path string) ([]*SloDefinition, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("on attempt to read -slo-file (%s): %s", path, err.Error())
+	}
+
+	var sloFile SloFile
+	if err := yaml.Unmarshal(contents, &sloFile); err != nil {
+		return nil, fmt.Errorf("on attempt to parse -slo-file (%s): %s", path, err.Error())
+	}
+
+	defs := make([]*SloDefinition, 0, len(sloFile.Slos))
+
+	for _, entry := range sloFile.Slos {
+		matches := sloExpressionPattern.FindStringSubmatch(entry.Metric + entry.Threshold)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid slo entry in -slo-file (%s): metric=%q threshold=%q", path, entry.Metric, entry.Threshold)
+		}
+
+		thresholdValue, err := ParseSloThresholdValue(matches[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold in -slo-file (%s): %s", path, err.Error())
+		}
+
+		severity := entry.Severity
+		if severity == "" {
+			severity = "critical"
+		}
+
+		def := &SloDefinition{
+			Metric:         matches[1],
+			Comparator:     matches[2],
+			ThresholdValue: thresholdValue,
+			ThresholdText:  matches[2] + matches[3],
+			Severity:       severity,
+		}
+
+		if entry.Scope != nil {
+			def.Category = entry.Scope.Category
+			def.Key = entry.Scope.Key
+		}
+
+		defs = append(defs, def)
+	}
+
+	return defs, nil
+}
+
+// EvaluateSlos checks every definition against the aggregate summary, and, for scoped
+// definitions, against the matching method+uripath or responseCode key. It returns an error if
+// any definition's Category is neither "" (aggregate) nor a recognized scope, rather than
+// silently falling back to a default scope that may not be the one the author intended, and it
+// rejects a scoped "tps" metric outright: no per-key request rate is tracked, so evaluating one
+// would otherwise silently compare the threshold against 0 instead of a real observed value.
+func EvaluateSlos(summarizer *Summarizer, percentileIndex *PercentileIndex, defs []*SloDefinition) ([]*SloViolation, error) {
+	violations := []*SloViolation{}
+
+	aggregateStats, _ := summarizer.AggregateSummary()
+
+	for _, def := range defs {
+		if def.Category == "" {
+			observed, ok := ObservedSloValue(def.Metric, "aggregate", "", aggregateStats.NumberOfMatchingRequests, uint(aggregateStats.NumberOfSuccessfulRequests),
+				aggregateStats.AverageTPSRate, percentileIndex)
+			if ok && SloIsViolated(def, observed) {
+				violations = append(violations, &SloViolation{Path: "aggregate." + def.Metric, Observed: observed, Threshold: def.ThresholdText, Severity: def.Severity})
+			}
+
+			continue
+		}
+
+		if def.Metric == "tps" {
+			return nil, fmt.Errorf("slo definition for metric (tps) cannot be scoped to category (%s) key (%s): tps is only meaningful over the aggregate run duration", def.Category, def.Key)
+		}
+
+		var column DataColumn
+		switch def.Category {
+		case "method+uripath":
+			column = ColumnMethodAndURIPath
+		case "responseCode":
+			column = ColumnResponseCode
+		default:
+			return nil, fmt.Errorf("slo definition for metric (%s) has unrecognized scope category (%s): must be \"method+uripath\" or \"responseCode\"", def.Metric, def.Category)
+		}
+
+		for key, summary := range IndexColumnSummariesByKey(summarizer, column) {
+			if key != def.Key {
+				continue
+			}
+
+			observed, ok := ObservedSloValue(def.Metric, def.Category, key, summary.NumberOfMatchingRequests, uint(summary.NumberOfSuccessfulRequests), 0, percentileIndex)
+			if ok && SloIsViolated(def, observed) {
+				violations = append(violations, &SloViolation{Path: def.Category + "." + key + "." + def.Metric, Observed: observed, Threshold: def.ThresholdText, Severity: def.Severity})
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+func ObservedSloValue(metric string, category string, key string, totalRequests uint, successfulRequests uint, tps float64, percentileIndex *PercentileIndex) (float64, bool) {
+	switch metric {
+	case "ttfb.p50":
+		return percentileIndex.TTFBPercentile(category, key, 50), true
+	case "ttfb.p95":
+		return percentileIndex.TTFBPercentile(category, key, 95), true
+	case "ttfb.p99":
+		return percentileIndex.TTFBPercentile(category, key, 99), true
+	case "ttlb.p50":
+		return percentileIndex.TTLBPercentile(category, key, 50), true
+	case "ttlb.p95":
+		return percentileIndex.TTLBPercentile(category, key, 95), true
+	case "ttlb.p99":
+		return percentileIndex.TTLBPercentile(category, key, 99), true
+	case "error_rate":
+		return FailureRatePercent(totalRequests, successfulRequests), true
+	case "tps":
+		return tps, true
+	default:
+		return 0, false
+	}
+}
+
+func SloIsViolated(def *SloDefinition, observed float64) bool {
+	switch def.Comparator {
+	case "<=":
+		return observed > def.ThresholdValue
+	case "<":
+		return observed >= def.ThresholdValue
+	case ">=":
+		return observed < def.ThresholdValue
+	case ">":
+		return observed <= def.ThresholdValue
+	case "==":
+		return observed != def.ThresholdValue
+	default:
+		return false
+	}
+}
+
+// ReportSloViolations writes the violations as a JSON array to pathToOutputFile (or stderr if
+// empty), and emits one "threshold-crossed" log line per violation to stderr so CI systems can
+// grep for regressions without parsing CSV.
+func ReportSloViolations(pathToOutputFile string, violations []*SloViolation) error {
+	for _, violation := range violations {
+		fmt.Fprintf(os.Stderr, "[SLO] threshold-crossed path=%s observed=%0.2f threshold=%s severity=%s\n",
+			violation.Path, violation.Observed, violation.Threshold, violation.Severity)
+	}
+
+	reportJSON, err := json.MarshalIndent(violations, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if pathToOutputFile == "" {
+		fmt.Fprintln(os.Stderr, string(reportJSON))
+		return nil
+	}
+
+	outputFile, err := os.Create(pathToOutputFile)
+	if err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+	defer outputFile.Close()
+
+	if _, err := outputFile.Write(reportJSON); err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+
+	return nil
+}
+
+// EvaluateAndReportSlos loads the configured SLOs from -slo and -slo-file, evaluates them
+// against summarizer, writes the violation report, and returns true if any SLO was violated.
+func EvaluateAndReportSlos(cliArgs *CommandLineArguments, summarizer *Summarizer, percentileIndex *PercentileIndex) (bool, error) {
+	defs, err := ParseSloExpressions(cliArgs.SloExpressions)
+	if err != nil {
+		return false, err
+	}
+
+	if cliArgs.PathToSloFile != "" {
+		fileDefs, err := LoadSloDefinitionsFromFile(cliArgs.PathToSloFile)
+		if err != nil {
+			return false, err
+		}
+
+		defs = append(defs, fileDefs...)
+	}
+
+	if len(defs) == 0 {
+		return false, nil
+	}
+
+	violations, err := EvaluateSlos(summarizer, percentileIndex, defs)
+	if err != nil {
+		return false, err
+	}
+
+	if err := ReportSloViolations(cliArgs.PathToSloOutputFile, violations); err != nil {
+		return false, err
+	}
+
+	return len(violations) > 0, nil
+}
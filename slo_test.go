@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestParseSloExpressionsAcceptsTheDocumentedExampleSet(t *testing.T) {
+	defs, err := ParseSloExpressions([]string{"ttfb.p95<=250ms,ttlb.p99<=1s,error_rate<=0.5%,tps>=500"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	expected := []struct {
+		metric         string
+		comparator     string
+		thresholdValue float64
+	}{
+		{"ttfb.p95", "<=", 250},
+		{"ttlb.p99", "<=", 1000},
+		{"error_rate", "<=", 0.5},
+		{"tps", ">=", 500},
+	}
+
+	if len(defs) != len(expected) {
+		t.Fatalf("expected %d definitions, got %d", len(expected), len(defs))
+	}
+
+	for i, want := range expected {
+		if defs[i].Metric != want.metric {
+			t.Errorf("definition %d: expected metric (%s), got (%s)", i, want.metric, defs[i].Metric)
+		}
+		if defs[i].Comparator != want.comparator {
+			t.Errorf("definition %d: expected comparator (%s), got (%s)", i, want.comparator, defs[i].Comparator)
+		}
+		if defs[i].ThresholdValue != want.thresholdValue {
+			t.Errorf("definition %d: expected threshold value (%v), got (%v)", i, want.thresholdValue, defs[i].ThresholdValue)
+		}
+	}
+}
+
+func TestSloExpressionPatternMatchesMetricNamesContainingDigits(t *testing.T) {
+	for _, expression := range []string{"ttfb.p95<=250ms", "ttlb.p99<=1s"} {
+		if !sloExpressionPattern.MatchString(expression) {
+			t.Errorf("expected sloExpressionPattern to match (%s)", expression)
+		}
+	}
+}
+
+func TestEvaluateSlosRejectsAScopedTpsMetric(t *testing.T) {
+	summarizer := NewSummarizer(false, 0, 0)
+	summarizer.Record(JtlRow{TimestampAsUnixEpochMs: 0, MethodAndURIPath: "GET /a", Success: true})
+	summarizer.Record(JtlRow{TimestampAsUnixEpochMs: 1000, MethodAndURIPath: "GET /a", Success: true})
+
+	defs := []*SloDefinition{{
+		Metric:         "tps",
+		Comparator:     ">=",
+		ThresholdValue: 1,
+		ThresholdText:  ">=1",
+		Category:       "method+uripath",
+		Key:            "GET /a",
+		Severity:       "critical",
+	}}
+
+	_, err := EvaluateSlos(summarizer, summarizer.PercentileIndex(), defs)
+	if err == nil {
+		t.Fatal("expected an error for a scoped tps SLO, got nil")
+	}
+}
+
+func TestEvaluateSlosAcceptsAnUnscopedTpsMetric(t *testing.T) {
+	summarizer := NewSummarizer(false, 0, 0)
+	summarizer.Record(JtlRow{TimestampAsUnixEpochMs: 0, MethodAndURIPath: "GET /a", Success: true})
+	summarizer.Record(JtlRow{TimestampAsUnixEpochMs: 1000, MethodAndURIPath: "GET /a", Success: true})
+
+	defs := []*SloDefinition{{
+		Metric:         "tps",
+		Comparator:     ">=",
+		ThresholdValue: 1000,
+		ThresholdText:  ">=1000",
+		Severity:       "critical",
+	}}
+
+	violations, err := EvaluateSlos(summarizer, summarizer.PercentileIndex(), defs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(violations) != 1 {
+		t.Fatalf("expected 1 violation (tps below the 1000 threshold), got %d", len(violations))
+	}
+}
+
+func TestEvaluateSlosRejectsAnUnrecognizedScopeCategory(t *testing.T) {
+	summarizer := NewSummarizer(false, 0, 0)
+	summarizer.Record(JtlRow{TimestampAsUnixEpochMs: 0, MethodAndURIPath: "GET /a", Success: true})
+
+	defs := []*SloDefinition{{
+		Metric:         "ttfb.p95",
+		Comparator:     "<=",
+		ThresholdValue: 250,
+		ThresholdText:  "<=250ms",
+		Category:       "bogusCategory",
+		Key:            "GET /a",
+		Severity:       "critical",
+	}}
+
+	if _, err := EvaluateSlos(summarizer, summarizer.PercentileIndex(), defs); err == nil {
+		t.Fatal("expected an error for an unrecognized scope category, got nil")
+	}
+}
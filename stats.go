@@ -0,0 +1,96 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// WelfordAccumulator maintains count/min/max and, via Welford's online algorithm, mean and
+// population variance, without retaining the individual samples.
+type WelfordAccumulator struct {
+	Count   uint64
+	Mean    float64
+	Minimum float64
+	Maximum float64
+	m2      float64
+}
+
+func (a *WelfordAccumulator) Add(value float64) {
+	if a.Count == 0 {
+		a.Minimum = value
+		a.Maximum = value
+	} else if value < a.Minimum {
+		a.Minimum = value
+	} else if value > a.Maximum {
+		a.Maximum = value
+	}
+
+	a.Count++
+	delta := value - a.Mean
+	a.Mean += delta / float64(a.Count)
+	a.m2 += delta * (value - a.Mean)
+}
+
+func (a *WelfordAccumulator) PopulationVariance() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+
+	return a.m2 / float64(a.Count)
+}
+
+func (a *WelfordAccumulator) PopulationStandardDeviation() float64 {
+	return math.Sqrt(a.PopulationVariance())
+}
+
+// ReservoirSample implements Algorithm R: the first Size values added are kept outright, and
+// each subsequent value replaces a uniformly-random existing slot with probability Size/seen, so
+// the sample stays a uniform random subset of everything seen without ever growing past Size.
+type ReservoirSample struct {
+	Size   int
+	Values []float64
+	seen   uint64
+}
+
+func NewReservoirSample(size int) *ReservoirSample {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &ReservoirSample{Size: size, Values: make([]float64, 0, size)}
+}
+
+func (r *ReservoirSample) Add(value float64) {
+	r.seen++
+
+	if len(r.Values) < r.Size {
+		r.Values = append(r.Values, value)
+		return
+	}
+
+	if j := rand.Int63n(int64(r.seen)); j < int64(r.Size) {
+		r.Values[j] = value
+	}
+}
+
+// ValueAtPercentile returns the nearest-rank percentile value from the current sample. It is
+// approximate once more values have been seen than the reservoir can hold.
+func (r *ReservoirSample) ValueAtPercentile(percentile float64) float64 {
+	if len(r.Values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), r.Values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Statistics is the basic TTFB/TTLB distribution summary reported for the aggregate and for each
+// category/key: mean, population standard deviation, min/max, and the 5th/95th percentile always
+// shown in the base summary columns. PercentilesAreApproximate is set when those two percentiles
+// came from a reservoir sample (-stream) rather than an exact sort of every observed value.
+// Arbitrary additional percentiles (-p/-hdr) are served separately by a PercentileIndex, which
+// uses a bounded-memory Histogram regardless of -stream.
+type Statistics struct {
+	Mean                        float64
+	PopulationStandardDeviation float64
+	Minimum                     float64
+	Maximum                     float64
+	ValueAt5thPercentile        float64
+	ValueAt95thPercentile       float64
+	PercentilesAreApproximate   bool
+}
+
+// AggregateStats is the whole-run summary: totals, TTFB/TTLB Statistics, and throughput.
+// AverageTPSRate is total requests over total wall-clock duration; MovingTPSStatistics instead
+// summarizes the distribution of requests-per-second across the run (see movingTPSStatistics),
+// so a bursty run and a steady one with the same average are distinguishable.
+type AggregateStats struct {
+	NumberOfMatchingRequests               uint
+	NumberOfSuccessfulRequests             uint
+	TimeToFirstByteStatistics              *Statistics
+	TimeToLastByteStatistics               *Statistics
+	AverageTPSRate                         float64
+	MovingTPSStatistics                    *Statistics
+	TimestampOfFirstDataEntryAsUnixEpochMs int64
+	TimestampOfLastDataEntryAsUnixEpochMs  int64
+}
+
+// ColumnSummary is the same per-key summary as AggregateStats, plus the key itself (e.g. a
+// method+uripath or a responseCode value).
+type ColumnSummary struct {
+	Key                        string
+	NumberOfMatchingRequests   uint
+	NumberOfSuccessfulRequests uint
+	TimeToFirstByteStatistics  *Statistics
+	TimeToLastByteStatistics   *Statistics
+}
+
+func (s *ColumnSummary) KeyAsAString() string {
+	return s.Key
+}
+
+// DataColumn names one of the groupings a Summarizer can break results down by.
+type DataColumn int
+
+const (
+	ColumnMethodAndURIPath DataColumn = iota
+	ColumnResponseCode
+	ColumnResponseSizeInBytes
+	ColumnRequestBodySizeInBytes
+)
+
+// keyAccumulator holds the running statistics for one category/key (or the aggregate): exact
+// count/success-count via plain counters, exact mean/stdev/min/max via Welford's online
+// algorithm, and either an Algorithm R reservoir (streaming mode, bounded memory, approximate
+// 5th/95th) or the full set of observed values (non-streaming mode, exact 5th/95th).
+type keyAccumulator struct {
+	count           uint64
+	successCount    uint64
+	ttfbWelford     WelfordAccumulator
+	ttlbWelford     WelfordAccumulator
+	ttfbReservoir   *ReservoirSample
+	ttlbReservoir   *ReservoirSample
+	ttfbExactValues []float64
+	ttlbExactValues []float64
+	ttfbHistogram   *Histogram
+	ttlbHistogram   *Histogram
+}
+
+func newKeyAccumulator(streaming bool, reservoirSize int, significantFigures int) *keyAccumulator {
+	acc := &keyAccumulator{
+		ttfbHistogram: NewHistogram(significantFigures),
+		ttlbHistogram: NewHistogram(significantFigures),
+	}
+
+	if streaming {
+		acc.ttfbReservoir = NewReservoirSample(reservoirSize)
+		acc.ttlbReservoir = NewReservoirSample(reservoirSize)
+	}
+
+	return acc
+}
+
+func (acc *keyAccumulator) add(streaming bool, row JtlRow) {
+	acc.count++
+	if row.Success {
+		acc.successCount++
+	}
+
+	acc.ttfbWelford.Add(row.TimeToFirstByteMs)
+	acc.ttlbWelford.Add(row.TimeToLastByteMs)
+	acc.ttfbHistogram.RecordValue(row.TimeToFirstByteMs)
+	acc.ttlbHistogram.RecordValue(row.TimeToLastByteMs)
+
+	if streaming {
+		acc.ttfbReservoir.Add(row.TimeToFirstByteMs)
+		acc.ttlbReservoir.Add(row.TimeToLastByteMs)
+	} else {
+		acc.ttfbExactValues = append(acc.ttfbExactValues, row.TimeToFirstByteMs)
+		acc.ttlbExactValues = append(acc.ttlbExactValues, row.TimeToLastByteMs)
+	}
+}
+
+// exactPercentile returns the nearest-rank percentile value from values, using the same
+// ceil-based rank (matching ReservoirSample.ValueAtPercentile and Histogram.ValueAtPercentile) so
+// switching -stream on or off for the same data reports the same percentile definition.
+func exactPercentile(values []float64, percentile float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
+
+func (acc *keyAccumulator) ttfbStatistics(streaming bool) *Statistics {
+	return statisticsFrom(streaming, &acc.ttfbWelford, acc.ttfbReservoir, acc.ttfbExactValues)
+}
+
+func (acc *keyAccumulator) ttlbStatistics(streaming bool) *Statistics {
+	return statisticsFrom(streaming, &acc.ttlbWelford, acc.ttlbReservoir, acc.ttlbExactValues)
+}
+
+func statisticsFrom(streaming bool, welford *WelfordAccumulator, reservoir *ReservoirSample, exactValues []float64) *Statistics {
+	stats := &Statistics{
+		Mean:                        welford.Mean,
+		PopulationStandardDeviation: welford.PopulationStandardDeviation(),
+		Minimum:                     welford.Minimum,
+		Maximum:                     welford.Maximum,
+		PercentilesAreApproximate:   streaming,
+	}
+
+	if streaming {
+		stats.ValueAt5thPercentile = reservoir.ValueAtPercentile(5)
+		stats.ValueAt95thPercentile = reservoir.ValueAtPercentile(95)
+	} else {
+		stats.ValueAt5thPercentile = exactPercentile(exactValues, 5)
+		stats.ValueAt95thPercentile = exactPercentile(exactValues, 95)
+	}
+
+	return stats
+}
+
+// Summarizer aggregates JtlRows into an overall AggregateStats plus per-key ColumnSummaries for
+// each DataColumn, in fixed memory per key. In streaming mode, percentiles are approximate
+// (Algorithm R reservoir); otherwise they are exact (every value is retained and sorted at
+// report time), matching how the tool behaved before -stream existed. Every key also gets a
+// bounded-memory TTFB/TTLB Histogram, built in the same pass, so PercentileIndex() never has to
+// re-read the source file.
+type Summarizer struct {
+	streaming          bool
+	reservoirSize      int
+	significantFigures int
+	seenAnyRow         bool
+	firstTimestampMs   int64
+	lastTimestampMs    int64
+	aggregate          *keyAccumulator
+	byColumn           map[DataColumn]map[string]*keyAccumulator
+	requestsPerSecond  map[int64]uint64
+}
+
+func NewSummarizer(streaming bool, reservoirSize int, significantFigures int) *Summarizer {
+	if reservoirSize <= 0 {
+		reservoirSize = 10000
+	}
+
+	if significantFigures <= 0 {
+		significantFigures = DefaultHistogramSignificantFigures
+	}
+
+	return &Summarizer{
+		streaming:          streaming,
+		reservoirSize:      reservoirSize,
+		significantFigures: significantFigures,
+		aggregate:          newKeyAccumulator(streaming, reservoirSize, significantFigures),
+		byColumn: map[DataColumn]map[string]*keyAccumulator{
+			ColumnMethodAndURIPath:       {},
+			ColumnResponseCode:           {},
+			ColumnResponseSizeInBytes:    {},
+			ColumnRequestBodySizeInBytes: {},
+		},
+		requestsPerSecond: map[int64]uint64{},
+	}
+}
+
+func (s *Summarizer) accumulatorFor(column DataColumn, key string) *keyAccumulator {
+	keyed := s.byColumn[column]
+
+	acc, ok := keyed[key]
+	if !ok {
+		acc = newKeyAccumulator(s.streaming, s.reservoirSize, s.significantFigures)
+		keyed[key] = acc
+	}
+
+	return acc
+}
+
+// Record folds one row into the aggregate and every per-column key it belongs to.
+func (s *Summarizer) Record(row JtlRow) {
+	if !s.seenAnyRow || row.TimestampAsUnixEpochMs < s.firstTimestampMs {
+		s.firstTimestampMs = row.TimestampAsUnixEpochMs
+	}
+	if row.TimestampAsUnixEpochMs > s.lastTimestampMs {
+		s.lastTimestampMs = row.TimestampAsUnixEpochMs
+	}
+	s.seenAnyRow = true
+
+	s.requestsPerSecond[row.TimestampAsUnixEpochMs/1000]++
+
+	s.aggregate.add(s.streaming, row)
+	s.accumulatorFor(ColumnMethodAndURIPath, row.MethodAndURIPath).add(s.streaming, row)
+	s.accumulatorFor(ColumnResponseCode, row.ResponseCode).add(s.streaming, row)
+	s.accumulatorFor(ColumnResponseSizeInBytes, strconv.FormatInt(row.ResponseBytesReceived, 10)).add(s.streaming, row)
+	s.accumulatorFor(ColumnRequestBodySizeInBytes, strconv.FormatInt(row.RequestBodySizeInBytes, 10)).add(s.streaming, row)
+}
+
+func (s *Summarizer) AggregateSummary() (*AggregateStats, error) {
+	durationInSeconds := float64(s.lastTimestampMs-s.firstTimestampMs) / 1000
+
+	averageTPSRate := float64(0)
+	if durationInSeconds > 0 {
+		averageTPSRate = float64(s.aggregate.count) / durationInSeconds
+	}
+
+	return &AggregateStats{
+		NumberOfMatchingRequests:               uint(s.aggregate.count),
+		NumberOfSuccessfulRequests:             uint(s.aggregate.successCount),
+		TimeToFirstByteStatistics:              s.aggregate.ttfbStatistics(s.streaming),
+		TimeToLastByteStatistics:               s.aggregate.ttlbStatistics(s.streaming),
+		AverageTPSRate:                         averageTPSRate,
+		MovingTPSStatistics:                    s.movingTPSStatistics(),
+		TimestampOfFirstDataEntryAsUnixEpochMs: s.firstTimestampMs,
+		TimestampOfLastDataEntryAsUnixEpochMs:  s.lastTimestampMs,
+	}, nil
+}
+
+// movingTPSStatistics computes exact mean/stdev/min/max/5th/95th-percentile statistics over the
+// per-second request-count buckets built up in Record, without ever retaining the raw rows
+// themselves: requestsPerSecond holds one counter per distinct floor(timestamp_ms/1000) second,
+// so its memory cost is bounded by the run's wall-clock duration rather than its request count.
+func (s *Summarizer) movingTPSStatistics() *Statistics {
+	if len(s.requestsPerSecond) == 0 {
+		return &Statistics{}
+	}
+
+	welford := WelfordAccumulator{}
+	values := make([]float64, 0, len(s.requestsPerSecond))
+
+	for _, count := range s.requestsPerSecond {
+		value := float64(count)
+		welford.Add(value)
+		values = append(values, value)
+	}
+
+	return &Statistics{
+		Mean:                        welford.Mean,
+		PopulationStandardDeviation: welford.PopulationStandardDeviation(),
+		Minimum:                     welford.Minimum,
+		Maximum:                     welford.Maximum,
+		ValueAt5thPercentile:        exactPercentile(values, 5),
+		ValueAt95thPercentile:       exactPercentile(values, 95),
+	}
+}
+
+// categoryLabelForColumn names the scope category each DataColumn is reported under in a
+// PercentileIndex and in the summary/compare/Prometheus output, matching GenerateSummaryOutputText.
+var categoryLabelForColumn = map[DataColumn]string{
+	ColumnMethodAndURIPath:       "method+uripath",
+	ColumnResponseCode:           "responseCode",
+	ColumnResponseSizeInBytes:    "responseSizeInBytes",
+	ColumnRequestBodySizeInBytes: "requestBodyInBytes",
+}
+
+// PercentileIndex builds a PercentileIndex from the Histograms already accumulated during
+// Record, so arbitrary percentiles (-p/-hdr/-prom/-pushgateway/-slo) are available without a
+// second pass over the source file.
+func (s *Summarizer) PercentileIndex() *PercentileIndex {
+	idx := newPercentileIndex()
+
+	idx.set("aggregate", "", s.aggregate.ttfbHistogram, s.aggregate.ttlbHistogram)
+
+	for column, label := range categoryLabelForColumn {
+		for key, acc := range s.byColumn[column] {
+			idx.set(label, key, acc.ttfbHistogram, acc.ttlbHistogram)
+		}
+	}
+
+	return idx
+}
+
+func (s *Summarizer) SummariesForTheColumn(column DataColumn) ([]*ColumnSummary, error) {
+	keyed := s.byColumn[column]
+
+	summaries := make([]*ColumnSummary, 0, len(keyed))
+	for key, acc := range keyed {
+		summaries = append(summaries, &ColumnSummary{
+			Key:                        key,
+			NumberOfMatchingRequests:   uint(acc.count),
+			NumberOfSuccessfulRequests: uint(acc.successCount),
+			TimeToFirstByteStatistics:  acc.ttfbStatistics(s.streaming),
+			TimeToLastByteStatistics:   acc.ttlbStatistics(s.streaming),
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Key < summaries[j].Key })
+
+	return summaries, nil
+}
+
+// NewSummarizerFromCsv reads every row of the JTL source file at path and returns a Summarizer
+// over it: streaming (Algorithm R reservoir, fixed memory, approximate 5th/95th) if streaming is
+// true, or buffered (every value retained, exact 5th/95th) otherwise. significantFigures controls
+// the precision of the Histograms built alongside it (see PercentileIndex).
+func NewSummarizerFromCsv(path string, streaming bool, reservoirSize int, significantFigures int) (*Summarizer, []*JtlRowError, error) {
+	jtlFile, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer jtlFile.Close()
+
+	summarizer := NewSummarizer(streaming, reservoirSize, significantFigures)
+
+	rowErrors, err := ReadJtlRows(jtlFile, summarizer.Record)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !summarizer.seenAnyRow {
+		return nil, nil, fmt.Errorf("(%s) contains no JTL data rows", path)
+	}
+
+	return summarizer, rowErrors, nil
+}
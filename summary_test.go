@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+// TestExactPercentileUsesCeilBasedNearestRank pins exactPercentile to the same nearest-rank
+// definition as ReservoirSample.ValueAtPercentile/Histogram.ValueAtPercentile: for 37 values
+// (1..37), the ceil-based formula puts p95 at rank ceil(0.95*37)-1 = 35, i.e. value 36, where the
+// old truncating formula (37*95/100-1 = 34) would have picked value 35 instead.
+func TestExactPercentileUsesCeilBasedNearestRank(t *testing.T) {
+	values := make([]float64, 37)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+
+	// ceil-based: rank = ceil(95/100*37)-1 = ceil(35.15)-1 = 36-1 = 35 -> values[35] = 36
+	// truncating (the old bug): rank = 37*95/100-1 = 35-1 = 34 -> values[34] = 35
+	if got := exactPercentile(values, 95); got != 36 {
+		t.Errorf("expected p95 of 1..37 to be 36 (ceil-based nearest rank), got %v", got)
+	}
+}
+
+func TestExactPercentileMatchesHistogramForTheSameData(t *testing.T) {
+	values := make([]float64, 37)
+	h := NewHistogram(DefaultHistogramSignificantFigures)
+	for i := range values {
+		values[i] = float64(i + 1)
+		h.RecordValue(values[i])
+	}
+
+	for _, p := range []float64{5, 50, 95, 99} {
+		if got, want := exactPercentile(values, p), h.ValueAtPercentile(p); got != want {
+			t.Errorf("p%v: exactPercentile=%v, Histogram.ValueAtPercentile=%v (should agree on rank)", p, got, want)
+		}
+	}
+}
+
+func TestExactPercentileHandlesEmptyInput(t *testing.T) {
+	if got := exactPercentile(nil, 95); got != 0 {
+		t.Errorf("expected 0 for empty input, got %v", got)
+	}
+}
+
+func TestMovingTPSStatisticsSummarizesPerSecondBuckets(t *testing.T) {
+	s := NewSummarizer(false, 0, 0)
+
+	// second 0: 2 requests, second 1: 4 requests, second 2: 2 requests
+	rows := []JtlRow{
+		{TimestampAsUnixEpochMs: 0, Success: true},
+		{TimestampAsUnixEpochMs: 500, Success: true},
+		{TimestampAsUnixEpochMs: 1000, Success: true},
+		{TimestampAsUnixEpochMs: 1200, Success: true},
+		{TimestampAsUnixEpochMs: 1400, Success: true},
+		{TimestampAsUnixEpochMs: 1900, Success: true},
+		{TimestampAsUnixEpochMs: 2000, Success: true},
+		{TimestampAsUnixEpochMs: 2999, Success: true},
+	}
+	for _, row := range rows {
+		s.Record(row)
+	}
+
+	aggregate, err := s.AggregateSummary()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	moving := aggregate.MovingTPSStatistics
+	if moving.Mean != float64(8)/3 {
+		t.Errorf("expected moving TPS mean %v, got %v", float64(8)/3, moving.Mean)
+	}
+	if moving.Minimum != 2 {
+		t.Errorf("expected moving TPS minimum 2, got %v", moving.Minimum)
+	}
+	if moving.Maximum != 4 {
+		t.Errorf("expected moving TPS maximum 4, got %v", moving.Maximum)
+	}
+}
+
+func TestMovingTPSStatisticsOnEmptySummarizerIsZeroNotNil(t *testing.T) {
+	s := NewSummarizer(false, 0, 0)
+
+	aggregate, err := s.AggregateSummary()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if aggregate.MovingTPSStatistics == nil {
+		t.Fatal("expected a non-nil (zero-value) MovingTPSStatistics when no rows were recorded")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	// github.com/ziutek/rrd cgo-binds librrd, so building (or running "go test") anything that
+	// imports this file requires the librrd development headers/library installed on the host
+	// (e.g. "apt-get install librrd-dev" or "brew install rrdtool").
+	"github.com/ziutek/rrd"
+)
+
+// TimeSeriesInterval is the aggregate of every JtlRow whose timestamp fell in one bucket of
+// width equal to the interval passed to NewTimeSeriesFromJtlFile.
+type TimeSeriesInterval struct {
+	StartTimestampAsUnixEpochMs   int64
+	TPS                           float64
+	ErrorRatePercent              float64
+	TimeToFirstByte50thPercentile float64
+	TimeToFirstByte95thPercentile float64
+	TimeToLastByte50thPercentile  float64
+	TimeToLastByte95thPercentile  float64
+	ConcurrentInFlightRequests    float64
+}
+
+// TimeSeries is a run bucketed into fixed-width intervals, in chronological order.
+type TimeSeries struct {
+	Intervals []*TimeSeriesInterval
+}
+
+type timeSeriesBucketAccumulator struct {
+	count                  uint64
+	successCount           uint64
+	ttfb                   *Histogram
+	ttlb                   *Histogram
+	concurrentThreadsTotal int64
+}
+
+// NewTimeSeriesFromJtlFile scans the JTL source file at path once, bucketing every row into
+// fixed-width intervals of the given duration, and returns the resulting TimeSeries in
+// chronological order.
+func NewTimeSeriesFromJtlFile(path string, interval time.Duration) (*TimeSeries, []*JtlRowError, error) {
+	jtlFile, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("on attempt to read (%s) for time-series bucketing: %s", path, err.Error())
+	}
+	defer jtlFile.Close()
+
+	intervalMs := interval.Milliseconds()
+	if intervalMs <= 0 {
+		return nil, nil, fmt.Errorf("-interval must be a positive duration")
+	}
+
+	rows := []JtlRow{}
+	firstTimestampMs := int64(-1)
+
+	rowErrors, err := ReadJtlRows(jtlFile, func(row JtlRow) {
+		if firstTimestampMs < 0 || row.TimestampAsUnixEpochMs < firstTimestampMs {
+			firstTimestampMs = row.TimestampAsUnixEpochMs
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if firstTimestampMs < 0 {
+		return nil, nil, fmt.Errorf("(%s) contains no JTL data rows", path)
+	}
+
+	buckets := map[int64]*timeSeriesBucketAccumulator{}
+	bucketOrder := []int64{}
+
+	for _, row := range rows {
+		bucketIndex := (row.TimestampAsUnixEpochMs - firstTimestampMs) / intervalMs
+
+		acc, ok := buckets[bucketIndex]
+		if !ok {
+			acc = &timeSeriesBucketAccumulator{
+				ttfb: NewHistogram(DefaultHistogramSignificantFigures),
+				ttlb: NewHistogram(DefaultHistogramSignificantFigures),
+			}
+			buckets[bucketIndex] = acc
+			bucketOrder = append(bucketOrder, bucketIndex)
+		}
+
+		acc.count++
+		if row.Success {
+			acc.successCount++
+		}
+		acc.ttfb.RecordValue(row.TimeToFirstByteMs)
+		acc.ttlb.RecordValue(row.TimeToLastByteMs)
+		acc.concurrentThreadsTotal += row.ConcurrentThreadsActive
+	}
+
+	sort.Slice(bucketOrder, func(i, j int) bool { return bucketOrder[i] < bucketOrder[j] })
+
+	series := &TimeSeries{Intervals: make([]*TimeSeriesInterval, 0, len(bucketOrder))}
+
+	intervalSeconds := interval.Seconds()
+
+	for _, bucketIndex := range bucketOrder {
+		acc := buckets[bucketIndex]
+
+		tps := float64(0)
+		if intervalSeconds > 0 {
+			tps = float64(acc.count) / intervalSeconds
+		}
+
+		series.Intervals = append(series.Intervals, &TimeSeriesInterval{
+			StartTimestampAsUnixEpochMs:   firstTimestampMs + bucketIndex*intervalMs,
+			TPS:                           tps,
+			ErrorRatePercent:              FailureRatePercent(uint(acc.count), uint(acc.successCount)),
+			TimeToFirstByte50thPercentile: acc.ttfb.ValueAtPercentile(50),
+			TimeToFirstByte95thPercentile: acc.ttfb.ValueAtPercentile(95),
+			TimeToLastByte50thPercentile:  acc.ttlb.ValueAtPercentile(50),
+			TimeToLastByte95thPercentile:  acc.ttlb.ValueAtPercentile(95),
+			ConcurrentInFlightRequests:    float64(acc.concurrentThreadsTotal) / float64(acc.count),
+		})
+	}
+
+	return series, rowErrors, nil
+}
+
+// WriteTimeSeriesOutputs bucket-aggregates the JTL source file on cliArgs.TimeSeriesInterval and
+// writes the requested -ts-csv and/or -rrd outputs.
+func WriteTimeSeriesOutputs(cliArgs *CommandLineArguments) error {
+	if cliArgs.PathToTimeSeriesCsvFile == "" && cliArgs.PathToRrdFile == "" {
+		return fmt.Errorf("-interval requires -ts-csv and/or -rrd")
+	}
+
+	series, rowErrors, err := NewTimeSeriesFromJtlFile(cliArgs.PathToJtlSourceCsvFile, cliArgs.TimeSeriesInterval)
+	if err != nil {
+		return err
+	}
+	LogAnyRowsThatCannotBeProcessed(rowErrors)
+
+	if cliArgs.PathToTimeSeriesCsvFile != "" {
+		if err := WriteTimeSeriesCsv(cliArgs.PathToTimeSeriesCsvFile, series); err != nil {
+			return err
+		}
+	}
+
+	if cliArgs.PathToRrdFile != "" {
+		if err := WriteTimeSeriesToRrd(cliArgs.PathToRrdFile, series, cliArgs.TimeSeriesInterval); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func WriteTimeSeriesCsv(pathToOutputFile string, series *TimeSeries) error {
+	outputFile, err := os.Create(pathToOutputFile)
+	if err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+	defer outputFile.Close()
+
+	textBuffer := &bytes.Buffer{}
+	textBuffer.WriteString("TimestampUnixMs,TPS,ErrorRatePercent,TTFB P50,TTFB P95,TTLB P50,TTLB P95,ConcurrentInFlightRequests\n")
+
+	for _, interval := range series.Intervals {
+		fmt.Fprintf(textBuffer, "%d,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f,%0.2f\n",
+			interval.StartTimestampAsUnixEpochMs, interval.TPS, interval.ErrorRatePercent,
+			interval.TimeToFirstByte50thPercentile, interval.TimeToFirstByte95thPercentile,
+			interval.TimeToLastByte50thPercentile, interval.TimeToLastByte95thPercentile,
+			interval.ConcurrentInFlightRequests)
+	}
+
+	if _, err := outputFile.Write(textBuffer.Bytes()); err != nil {
+		return fmt.Errorf("on attempt to write to (%s): %s", pathToOutputFile, err.Error())
+	}
+
+	return nil
+}
+
+// WriteTimeSeriesToRrd creates an RRD at pathToRrdFile with one DS per metric and AVERAGE/MIN/MAX
+// RRAs at the given step, or, if the file already exists, resumes by updating only the intervals
+// after its last recorded timestamp.
+func WriteTimeSeriesToRrd(pathToRrdFile string, series *TimeSeries, step time.Duration) error {
+	stepInSeconds := uint(step.Seconds())
+	if stepInSeconds == 0 {
+		stepInSeconds = 1
+	}
+
+	lastUpdatedAt := int64(0)
+
+	if info, err := rrd.Info(pathToRrdFile); err == nil {
+		if lastUpdate, ok := info["last_update"].(uint); ok {
+			lastUpdatedAt = int64(lastUpdate)
+		}
+	} else {
+		if len(series.Intervals) == 0 {
+			return fmt.Errorf("no intervals to seed RRD creation at (%s)", pathToRrdFile)
+		}
+
+		firstIntervalStart := time.UnixMilli(series.Intervals[0].StartTimestampAsUnixEpochMs).Add(-step)
+
+		creator := rrd.NewCreator(pathToRrdFile, firstIntervalStart, stepInSeconds)
+		creator.DS("tps", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.DS("error_rate", "GAUGE", stepInSeconds*2, 0, 100)
+		creator.DS("ttfb_p50", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.DS("ttfb_p95", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.DS("ttlb_p50", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.DS("ttlb_p95", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.DS("in_flight", "GAUGE", stepInSeconds*2, 0, "U")
+		creator.RRA("AVERAGE", 0.5, 1, 86400)
+		creator.RRA("MIN", 0.5, 1, 86400)
+		creator.RRA("MAX", 0.5, 1, 86400)
+
+		if err := creator.Create(false); err != nil {
+			return fmt.Errorf("on attempt to create RRD (%s): %s", pathToRrdFile, err.Error())
+		}
+	}
+
+	updater := rrd.NewUpdater(pathToRrdFile)
+
+	for _, interval := range series.Intervals {
+		intervalTimestampInSeconds := interval.StartTimestampAsUnixEpochMs / 1000
+		if intervalTimestampInSeconds <= lastUpdatedAt {
+			continue
+		}
+
+		if err := updater.Update(time.Unix(intervalTimestampInSeconds, 0),
+			interval.TPS, interval.ErrorRatePercent,
+			interval.TimeToFirstByte50thPercentile, interval.TimeToFirstByte95thPercentile,
+			interval.TimeToLastByte50thPercentile, interval.TimeToLastByte95thPercentile,
+			interval.ConcurrentInFlightRequests); err != nil {
+			return fmt.Errorf("on attempt to write to (%s): %s", pathToRrdFile, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func writeTempJtlFile(t *testing.T, contents string) string {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "*.jtl")
+	if err != nil {
+		t.Fatalf("unexpected error creating temp file: %s", err.Error())
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(contents); err != nil {
+		t.Fatalf("unexpected error writing temp file: %s", err.Error())
+	}
+
+	return file.Name()
+}
+
+func TestNewTimeSeriesFromJtlFileBucketsRowsByInterval(t *testing.T) {
+	path := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n"+
+		"0,100,80,true,200,GET /a,10,100,2\n"+
+		"500,110,85,true,200,GET /a,10,100,2\n"+
+		"1000,120,90,true,200,GET /a,10,100,4\n"+
+		"1999,50,40,false,500,GET /a,10,100,4\n"+
+		"2000,200,150,true,200,GET /a,10,100,1\n")
+
+	series, rowErrors, err := NewTimeSeriesFromJtlFile(path, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if len(rowErrors) != 0 {
+		t.Fatalf("expected no row errors, got %d", len(rowErrors))
+	}
+
+	if len(series.Intervals) != 3 {
+		t.Fatalf("expected 3 one-second intervals, got %d", len(series.Intervals))
+	}
+
+	if series.Intervals[0].StartTimestampAsUnixEpochMs != 0 {
+		t.Errorf("expected interval 0 to start at 0, got %d", series.Intervals[0].StartTimestampAsUnixEpochMs)
+	}
+	if series.Intervals[0].TPS != 2 {
+		t.Errorf("expected interval 0 TPS 2, got %v", series.Intervals[0].TPS)
+	}
+
+	if series.Intervals[1].StartTimestampAsUnixEpochMs != 1000 {
+		t.Errorf("expected interval 1 to start at 1000, got %d", series.Intervals[1].StartTimestampAsUnixEpochMs)
+	}
+	if series.Intervals[1].ErrorRatePercent != 50 {
+		t.Errorf("expected interval 1 error rate 50%%, got %v", series.Intervals[1].ErrorRatePercent)
+	}
+
+	if series.Intervals[2].StartTimestampAsUnixEpochMs != 2000 {
+		t.Errorf("expected interval 2 to start at 2000, got %d", series.Intervals[2].StartTimestampAsUnixEpochMs)
+	}
+	if series.Intervals[2].TPS != 1 {
+		t.Errorf("expected interval 2 TPS 1, got %v", series.Intervals[2].TPS)
+	}
+}
+
+func TestNewTimeSeriesFromJtlFileRejectsANonPositiveInterval(t *testing.T) {
+	path := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n"+
+		"0,100,80,true,200,GET /a,10,100,2\n")
+
+	if _, _, err := NewTimeSeriesFromJtlFile(path, 0); err == nil {
+		t.Fatal("expected an error for a non-positive -interval, got nil")
+	}
+}
+
+func TestNewTimeSeriesFromJtlFileRejectsAFileWithNoDataRows(t *testing.T) {
+	path := writeTempJtlFile(t, "timeStamp,elapsed,Latency,success,responseCode,label,sentBytes,bytes,allThreads\n")
+
+	if _, _, err := NewTimeSeriesFromJtlFile(path, time.Second); err == nil {
+		t.Fatal("expected an error for a file with no data rows, got nil")
+	}
+}